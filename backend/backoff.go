@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// backoff computes exponentially growing retry delays. Jitter is the fraction
+// (0..1) of each delay that is randomized to spread retries of many peers.
+type backoff struct {
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+	jitter     float64
+	current    time.Duration
+}
+
+// next returns the delay to wait before the next attempt
+func (b *backoff) next() time.Duration {
+	if b.current == 0 {
+		b.current = b.initial
+	} else {
+		b.current = time.Duration(float64(b.current) * b.multiplier)
+	}
+	if b.max > 0 && b.current > b.max {
+		b.current = b.max
+	}
+	delay := b.current
+	if b.jitter > 0 {
+		spread := float64(delay) * b.jitter
+		delay = time.Duration(float64(delay) - spread + rand.Float64()*2*spread)
+	}
+	return delay
+}
+
+// reset starts over from the initial delay
+func (b *backoff) reset() {
+	b.current = 0
+}
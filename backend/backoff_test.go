@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/context"
+	gClient "github.com/omec-project/sctplb/sdcoreAmfServer"
+)
+
+func Test_Backoff(t *testing.T) {
+	b := backoff{initial: 100 * time.Millisecond, max: time.Second, multiplier: 2}
+	want := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for i, w := range want {
+		if got := b.next(); got != w {
+			t.Errorf("delay %d mismatch. got = %v, want = %v", i, got, w)
+		}
+	}
+	b.reset()
+	if got := b.next(); got != want[0] {
+		t.Errorf("delay after reset mismatch. got = %v, want = %v", got, want[0])
+	}
+}
+
+func Test_BackoffJitter(t *testing.T) {
+	b := backoff{initial: time.Second, max: time.Second, multiplier: 2, jitter: 0.2}
+	for range 100 {
+		if got := b.next(); got < 800*time.Millisecond || got > 1200*time.Millisecond {
+			t.Fatalf("jittered delay %v out of bounds", got)
+		}
+	}
+}
+
+func Test_RetryWindowRemovesBackend(t *testing.T) {
+	ctx := context.Sctplb_Self()
+	// a port nothing listens on
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	b := newGrpcServer("127.0.0.1", port, &config.Configuration{Reconnect: &config.Reconnect{
+		InitialInterval: 10 * time.Millisecond,
+		MaxInterval:     10 * time.Millisecond,
+		MaxRetryWindow:  50 * time.Millisecond,
+	}})
+	target := readyGrpcServer("10.4.0.9")
+	target.queue = newSendQueue(&config.SendQueue{Depth: 1})
+	ctx.AddNF(b)
+	ctx.AddNF(target)
+	t.Cleanup(func() { ctx.DeleteNF(target) })
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	ran := &context.Ran{Conn: local}
+	ran.SetRanId("gnb-4")
+	ctx.RanPool.Store(local, ran)
+	defer ctx.DeleteRan(local)
+	b.rans.Store(ran, struct{}{})
+
+	// the unreachable backend is removed and its gNBs are handed over
+	go b.ConnectToServer(port)
+	select {
+	case msg := <-target.queue.ch:
+		if msg.Msgtype != gClient.MsgType_GNB_CONN || msg.GnbId != "gnb-4" {
+			t.Errorf("handover message mismatch. got = %v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("gNB was not handed over")
+	}
+	if backendExists(b) {
+		t.Errorf("unreachable backend is still in the pool")
+	}
+	if b.State() != context.NFClosed {
+		t.Errorf("state mismatch. got = %v, want = %v", b.State(), context.NFClosed)
+	}
+}
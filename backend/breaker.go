@@ -0,0 +1,252 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/omec-project/sctplb/config"
+)
+
+// BreakerState is the state of the circuit breaker of a backend
+type BreakerState int
+
+const (
+	// BreakerClosed lets the scheduler pick the backend
+	BreakerClosed BreakerState = iota
+	// BreakerOpen takes the backend out of scheduling
+	BreakerOpen
+	// BreakerHalfOpen lets the scheduler pick the backend again until it
+	// either proves to work or fails
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+func (s BreakerState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+const (
+	defaultBreakerWindow              = 10 * time.Second
+	defaultBreakerErrorRate           = 0.5
+	defaultBreakerMinRequests         = 20
+	defaultBreakerConsecutiveFailures = 5
+	defaultBreakerOpenFor             = 30 * time.Second
+	defaultBreakerHalfOpenSuccesses   = 3
+)
+
+// breakerBuckets is the number of buckets the sliding window is split into
+const breakerBuckets = 10
+
+type breakerSettings struct {
+	window              time.Duration
+	errorRate           float64
+	minRequests         int
+	consecutiveFailures int
+	openFor             time.Duration
+	halfOpenSuccesses   int
+}
+
+var breakerConfig = defaultBreakerSettings()
+
+func defaultBreakerSettings() breakerSettings {
+	return breakerSettings{
+		window:              defaultBreakerWindow,
+		errorRate:           defaultBreakerErrorRate,
+		minRequests:         defaultBreakerMinRequests,
+		consecutiveFailures: defaultBreakerConsecutiveFailures,
+		openFor:             defaultBreakerOpenFor,
+		halfOpenSuccesses:   defaultBreakerHalfOpenSuccesses,
+	}
+}
+
+// SetCircuitBreaker configures the circuit breakers of the backends, it has to
+// be called before the backends are connected
+func SetCircuitBreaker(cfg *config.CircuitBreaker) {
+	breakerConfig = defaultBreakerSettings()
+	if cfg == nil {
+		return
+	}
+	if cfg.Window > 0 {
+		breakerConfig.window = cfg.Window
+	}
+	if cfg.ErrorRate > 0 && cfg.ErrorRate <= 1 {
+		breakerConfig.errorRate = cfg.ErrorRate
+	}
+	if cfg.MinRequests > 0 {
+		breakerConfig.minRequests = cfg.MinRequests
+	}
+	if cfg.ConsecutiveFailures > 0 {
+		breakerConfig.consecutiveFailures = cfg.ConsecutiveFailures
+	}
+	if cfg.OpenFor > 0 {
+		breakerConfig.openFor = cfg.OpenFor
+	}
+	if cfg.HalfOpenSuccesses > 0 {
+		breakerConfig.halfOpenSuccesses = cfg.HalfOpenSuccesses
+	}
+}
+
+// BreakerStatus is the externally visible state of a circuit breaker
+type BreakerStatus struct {
+	State BreakerState `json:"state"`
+	Since time.Time    `json:"since"`
+	// Requests and Failures are counted over the sliding window
+	Requests            uint64 `json:"requests"`
+	Failures            uint64 `json:"failures"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	Opened              uint64 `json:"opened"`
+}
+
+type breakerBucket struct {
+	slot     int64
+	requests uint64
+	failures uint64
+}
+
+// circuitBreaker takes a backend out of scheduling when its sends and
+// receives fail. It opens after consecutiveFailures failures in a row, or
+// when errorRate of at least minRequests results within the sliding window
+// failed. After openFor it is half-open, and closes again after
+// halfOpenSuccesses successes in a row, a failure opens it again. The zero
+// value is a closed breaker.
+type circuitBreaker struct {
+	mu          sync.Mutex
+	state       BreakerState
+	since       time.Time
+	buckets     [breakerBuckets]breakerBucket
+	consecutive int
+	successes   int
+	opened      uint64
+}
+
+// allows reports whether the scheduler may pick the backend, an open breaker
+// turns half-open once openFor expired
+func (c *circuitBreaker) allows(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == BreakerOpen && now.Sub(c.since) >= breakerConfig.openFor {
+		c.setState(BreakerHalfOpen, now)
+	}
+	return c.state != BreakerOpen
+}
+
+// record takes the result of a send or a receive
+func (c *circuitBreaker) record(ok bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch c.state {
+	case BreakerOpen:
+		// results of messages that were under way when it opened
+		return
+	case BreakerHalfOpen:
+		if !ok {
+			c.open(now)
+			return
+		}
+		c.successes++
+		if c.successes >= breakerConfig.halfOpenSuccesses {
+			c.setState(BreakerClosed, now)
+		}
+		return
+	}
+	b := c.bucket(now)
+	b.requests++
+	if ok {
+		c.consecutive = 0
+		return
+	}
+	b.failures++
+	c.consecutive++
+	requests, failures := c.counts(now)
+	if c.consecutive >= breakerConfig.consecutiveFailures ||
+		requests >= uint64(breakerConfig.minRequests) &&
+			float64(failures) >= breakerConfig.errorRate*float64(requests) {
+		c.open(now)
+	}
+}
+
+// reset closes the breaker of a backend whose new session got ready, the
+// failures of its previous session say nothing about the new one
+func (c *circuitBreaker) reset(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state != BreakerClosed {
+		c.setState(BreakerClosed, now)
+		return
+	}
+	c.buckets = [breakerBuckets]breakerBucket{}
+	c.consecutive = 0
+}
+
+func (c *circuitBreaker) open(now time.Time) {
+	c.opened++
+	c.setState(BreakerOpen, now)
+}
+
+// setState enters state, every state starts with a clean window
+func (c *circuitBreaker) setState(state BreakerState, now time.Time) {
+	c.state, c.since = state, now
+	c.buckets = [breakerBuckets]breakerBucket{}
+	c.consecutive, c.successes = 0, 0
+}
+
+func bucketWidth() time.Duration {
+	return max(breakerConfig.window/breakerBuckets, time.Millisecond)
+}
+
+// bucket returns the bucket of the window now falls in
+func (c *circuitBreaker) bucket(now time.Time) *breakerBucket {
+	slot := now.UnixNano() / int64(bucketWidth())
+	b := &c.buckets[slot%breakerBuckets]
+	if b.slot != slot {
+		*b = breakerBucket{slot: slot}
+	}
+	return b
+}
+
+// counts sums the buckets within the window
+func (c *circuitBreaker) counts(now time.Time) (requests, failures uint64) {
+	slot := now.UnixNano() / int64(bucketWidth())
+	for _, b := range c.buckets {
+		if slot-b.slot < breakerBuckets {
+			requests += b.requests
+			failures += b.failures
+		}
+	}
+	return requests, failures
+}
+
+func (c *circuitBreaker) status(now time.Time) BreakerStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	requests, failures := c.counts(now)
+	return BreakerStatus{
+		State:               c.state,
+		Since:               c.since,
+		Requests:            requests,
+		Failures:            failures,
+		ConsecutiveFailures: c.consecutive,
+		Opened:              c.opened,
+	}
+}
+
+// BreakerStatus returns the state of the circuit breaker of the backend
+func (b *GrpcServer) BreakerStatus() BreakerStatus {
+	return b.breaker.status(time.Now())
+}
@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/omec-project/sctplb/config"
+)
+
+func Test_CircuitBreaker(t *testing.T) {
+	defer SetCircuitBreaker(nil)
+	SetCircuitBreaker(&config.CircuitBreaker{
+		Window:              time.Second,
+		ErrorRate:           0.5,
+		MinRequests:         10,
+		ConsecutiveFailures: 3,
+		OpenFor:             time.Second,
+		HalfOpenSuccesses:   2,
+	})
+	now := time.Unix(1000, 0)
+
+	var c circuitBreaker
+	// failures in a row open the breaker
+	c.record(false, now)
+	c.record(false, now)
+	if !c.allows(now) {
+		t.Fatalf("breaker opened before %d consecutive failures", 3)
+	}
+	c.record(false, now)
+	if c.allows(now) {
+		t.Fatalf("breaker did not open after consecutive failures")
+	}
+	if s := c.status(now); s.State != BreakerOpen || s.Opened != 1 {
+		t.Errorf("status mismatch. got = %+v", s)
+	}
+
+	// it is half-open once OpenFor expired, a failure opens it again
+	now = now.Add(time.Second)
+	if !c.allows(now) || c.status(now).State != BreakerHalfOpen {
+		t.Fatalf("breaker is not half-open after OpenFor")
+	}
+	c.record(false, now)
+	if c.allows(now) {
+		t.Fatalf("failure in half-open did not open the breaker")
+	}
+
+	// successes in half-open close it
+	now = now.Add(time.Second)
+	c.allows(now)
+	c.record(true, now)
+	if c.status(now).State != BreakerHalfOpen {
+		t.Fatalf("breaker closed before %d successes", 2)
+	}
+	c.record(true, now)
+	if s := c.status(now); s.State != BreakerClosed || s.Opened != 2 {
+		t.Fatalf("status mismatch. got = %+v", s)
+	}
+
+	// the error rate opens it once enough results are in the window
+	for i := 0; i < 9; i++ {
+		c.record(i%2 == 0, now)
+	}
+	if !c.allows(now) {
+		t.Fatalf("breaker opened below MinRequests")
+	}
+	c.record(false, now)
+	if c.allows(now) {
+		t.Fatalf("breaker did not open on the error rate")
+	}
+}
+
+func Test_CircuitBreakerReset(t *testing.T) {
+	defer SetCircuitBreaker(nil)
+	SetCircuitBreaker(&config.CircuitBreaker{ConsecutiveFailures: 2})
+	now := time.Unix(1000, 0)
+
+	var c circuitBreaker
+	c.record(false, now)
+	c.record(false, now)
+	if c.allows(now) {
+		t.Fatalf("breaker did not open after consecutive failures")
+	}
+	// a new session closes it
+	c.reset(now)
+	if !c.allows(now) {
+		t.Fatalf("breaker is still open after the reset")
+	}
+	c.record(false, now)
+	c.reset(now)
+	c.record(false, now)
+	if !c.allows(now) || c.status(now).Opened != 1 {
+		t.Errorf("failures before the reset were counted. got = %+v", c.status(now))
+	}
+}
+
+func Test_CircuitBreakerWindow(t *testing.T) {
+	defer SetCircuitBreaker(nil)
+	SetCircuitBreaker(&config.CircuitBreaker{Window: time.Second, MinRequests: 4, ConsecutiveFailures: 10})
+	now := time.Unix(1000, 0)
+
+	var c circuitBreaker
+	c.record(false, now)
+	c.record(false, now)
+	c.record(true, now)
+	if s := c.status(now); s.Requests != 3 || s.Failures != 2 {
+		t.Fatalf("window counts mismatch. got = %+v", s)
+	}
+	// the old results slid out of the window
+	now = now.Add(time.Second)
+	if s := c.status(now); s.Requests != 0 || s.Failures != 0 {
+		t.Fatalf("window counts mismatch. got = %+v", s)
+	}
+	c.record(false, now)
+	if !c.allows(now) {
+		t.Fatalf("results out of the window opened the breaker")
+	}
+}
+
+func Test_BreakerEligibility(t *testing.T) {
+	defer SetCircuitBreaker(nil)
+	SetCircuitBreaker(&config.CircuitBreaker{ConsecutiveFailures: 1})
+	backends := failoverBackends(t, "10.9.0.1", "10.9.0.2")
+
+	backends[1].breaker.record(false, time.Now())
+	eligible := eligibleBackends()
+	if len(eligible) != 1 || eligible[0] != backends[0] {
+		t.Errorf("eligible backends mismatch. got = %v", eligible)
+	}
+	for _, s := range Status() {
+		if s.Address == backends[1].address && (s.Breaker.State != BreakerOpen || s.Healthy) {
+			t.Errorf("status mismatch. got = %+v", s)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"github.com/omec-project/sctplb/context"
+	"github.com/omec-project/sctplb/logger"
+	gClient "github.com/omec-project/sctplb/sdcoreAmfServer"
+)
+
+// broadcast delivers a non-UE message of the backend, e.g. an AMF
+// Configuration Update, to every gNB matching its filters
+func broadcast(response *gClient.AmfMessage) {
+	sent := 0
+	context.Sctplb_Self().RanPool.Range(func(key, value any) bool {
+		ran := value.(*context.Ran)
+		if ran.Conn == nil || !ranMatches(ran, response.PlmnFilter, response.TaiFilter) {
+			return true
+		}
+		if err := writeToRan(ran, response, nil); err != nil {
+			ran.Log.Warnf("broadcast from AMF %v failed: %v", response.AmfId, err)
+			return true
+		}
+		sent++
+		return true
+	})
+	logger.RanLog.Infof("broadcast from AMF %v sent to %d gNBs", response.AmfId, sent)
+}
+
+// ranMatches reports whether ran supports one of plmns and one of tais, an
+// empty filter matches every RAN. A RAN whose tracking areas are not known
+// yet only matches when there is no filter.
+func ranMatches(ran *context.Ran, plmns []*gClient.Plmn, tais []*gClient.Tai) bool {
+	if len(plmns) == 0 && len(tais) == 0 {
+		return true
+	}
+	supported := ran.SupportedTais()
+	if len(plmns) > 0 && !anyTai(supported, func(t context.Tai) bool {
+		for _, p := range plmns {
+			if p.Mcc == t.Mcc && p.Mnc == t.Mnc {
+				return true
+			}
+		}
+		return false
+	}) {
+		return false
+	}
+	if len(tais) > 0 && !anyTai(supported, func(t context.Tai) bool {
+		for _, f := range tais {
+			if f.Plmn != nil && f.Plmn.Mcc == t.Mcc && f.Plmn.Mnc == t.Mnc && f.Tac == t.Tac {
+				return true
+			}
+		}
+		return false
+	}) {
+		return false
+	}
+	return true
+}
+
+func anyTai(tais []context.Tai, match func(context.Tai) bool) bool {
+	for _, t := range tais {
+		if match(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"net"
+	"testing"
+
+	"github.com/omec-project/sctplb/context"
+	"github.com/omec-project/sctplb/logger"
+	gClient "github.com/omec-project/sctplb/sdcoreAmfServer"
+)
+
+func Test_RanMatches(t *testing.T) {
+	ran := &context.Ran{}
+	ran.SetSupportedTais([]context.Tai{{Mcc: "208", Mnc: "93", Tac: "000001"}})
+	unknown := &context.Ran{}
+	plmn := &gClient.Plmn{Mcc: "208", Mnc: "93"}
+	other := &gClient.Plmn{Mcc: "001", Mnc: "01"}
+
+	tests := []struct {
+		name  string
+		ran   *context.Ran
+		plmns []*gClient.Plmn
+		tais  []*gClient.Tai
+		want  bool
+	}{
+		{name: "no filter", ran: ran, want: true},
+		{name: "no filter unknown TAs", ran: unknown, want: true},
+		{name: "plmn match", ran: ran, plmns: []*gClient.Plmn{other, plmn}, want: true},
+		{name: "plmn mismatch", ran: ran, plmns: []*gClient.Plmn{other}, want: false},
+		{name: "plmn unknown TAs", ran: unknown, plmns: []*gClient.Plmn{plmn}, want: false},
+		{name: "tai match", ran: ran, tais: []*gClient.Tai{{Plmn: plmn, Tac: "000001"}}, want: true},
+		{name: "tai mismatch", ran: ran, tais: []*gClient.Tai{{Plmn: plmn, Tac: "000002"}}, want: false},
+		{
+			name:  "plmn and tai",
+			ran:   ran,
+			plmns: []*gClient.Plmn{plmn},
+			tais:  []*gClient.Tai{{Plmn: other, Tac: "000001"}},
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		if got := ranMatches(tt.ran, tt.plmns, tt.tais); got != tt.want {
+			t.Errorf("%s: ranMatches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_Broadcast(t *testing.T) {
+	ctx := context.Sctplb_Self()
+	newRan := func(tac string) (*context.Ran, net.Conn) {
+		local, remote := net.Pipe()
+		ran := &context.Ran{Conn: local, Log: logger.RanLog}
+		ran.SetSupportedTais([]context.Tai{{Mcc: "208", Mnc: "93", Tac: tac}})
+		ctx.RanPool.Store(local, ran)
+		t.Cleanup(func() {
+			ctx.DeleteRan(local)
+			local.Close()
+			remote.Close()
+		})
+		return ran, remote
+	}
+	_, matching := newRan("000001")
+	_, filtered := newRan("000002")
+
+	received := make(chan string, 2)
+	for name, conn := range map[string]net.Conn{"matching": matching, "filtered": filtered} {
+		go func() {
+			buf := make([]byte, 16)
+			if n, err := conn.Read(buf); err == nil {
+				received <- name + ":" + string(buf[:n])
+			}
+		}()
+	}
+
+	broadcast(&gClient.AmfMessage{
+		AmfId:      "amf-1",
+		Addressing: gClient.AddressMode_BROADCAST,
+		TaiFilter:  []*gClient.Tai{{Plmn: &gClient.Plmn{Mcc: "208", Mnc: "93"}, Tac: "000001"}},
+		Msg:        []byte("update"),
+	})
+	if got := <-received; got != "matching:update" {
+		t.Errorf("broadcast delivery mismatch. got = %q, want = %q", got, "matching:update")
+	}
+}
@@ -0,0 +1,377 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	stdctx "context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/context"
+	"github.com/omec-project/sctplb/logger"
+	"go.yaml.in/yaml/v4"
+)
+
+const (
+	DiscoveryDNS    = "dns"
+	DiscoverySRV    = "srv"
+	DiscoveryStatic = "static"
+	DiscoveryFile   = "file"
+)
+
+const (
+	IpFamilyV4 = "ipv4"
+	IpFamilyV6 = "ipv6"
+)
+
+const (
+	defaultRefreshInterval  = 2 * time.Second
+	defaultMaxRetryInterval = 30 * time.Second
+)
+
+// Endpoint is a backend NF address learnt through discovery. A zero Port
+// means the configured sctpGrpcPort is used, a zero Weight the weight of the
+// service.
+type Endpoint struct {
+	IP     net.IP
+	Port   int
+	Weight int
+}
+
+func (e Endpoint) String() string {
+	return net.JoinHostPort(e.IP.String(), strconv.Itoa(e.Port))
+}
+
+// Discovery returns the current set of backend NF endpoints for a service
+type Discovery interface {
+	Discover(ctx stdctx.Context) ([]Endpoint, error)
+}
+
+// NewDiscovery returns the discovery provider selected by svc.Discovery
+func NewDiscovery(svc config.Service) (Discovery, error) {
+	switch svc.Discovery {
+	case "", DiscoveryDNS:
+		if svc.Uri == "" {
+			return nil, errors.New("dns discovery requires uri")
+		}
+		return &dnsDiscovery{name: svc.Uri}, nil
+	case DiscoverySRV:
+		if svc.Uri == "" {
+			return nil, errors.New("srv discovery requires uri")
+		}
+		return &srvDiscovery{name: svc.Uri}, nil
+	case DiscoveryStatic:
+		endpoints, err := parseEndpoints(svc.Addresses)
+		if err != nil {
+			return nil, err
+		}
+		if len(endpoints) == 0 {
+			return nil, errors.New("static discovery requires addresses")
+		}
+		return &staticDiscovery{endpoints: endpoints}, nil
+	case DiscoveryFile:
+		if svc.File == "" {
+			return nil, errors.New("file discovery requires file")
+		}
+		return &fileDiscovery{path: svc.File}, nil
+	default:
+		return nil, fmt.Errorf("unsupported discovery type: %s", svc.Discovery)
+	}
+}
+
+// filterIpFamily keeps the endpoints of the given IP family, "" keeps all
+func filterIpFamily(endpoints []Endpoint, family string) []Endpoint {
+	if family == "" {
+		return endpoints
+	}
+	filtered := make([]Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		isV4 := ep.IP.To4() != nil
+		if (family == IpFamilyV4) == isV4 {
+			filtered = append(filtered, ep)
+		}
+	}
+	return filtered
+}
+
+// parseEndpoints accepts "ip" or "ip:port" entries, IPv6 addresses with a
+// port have to be written as "[ip]:port"
+func parseEndpoints(addresses []string) ([]Endpoint, error) {
+	endpoints := make([]Endpoint, 0, len(addresses))
+	for _, addr := range addresses {
+		ep, err := parseEndpoint(addr)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, nil
+}
+
+func parseEndpoint(addr string) (Endpoint, error) {
+	if ip := net.ParseIP(addr); ip != nil {
+		return Endpoint{IP: ip}, nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("invalid backend address %q: %w", addr, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return Endpoint{}, fmt.Errorf("invalid backend address %q: not an IP address", addr)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p <= 0 || p > 65535 {
+		return Endpoint{}, fmt.Errorf("invalid backend address %q: bad port", addr)
+	}
+	return Endpoint{IP: ip, Port: p}, nil
+}
+
+// dnsDiscovery resolves the A/AAAA records of a name, e.g. a headless k8s service
+type dnsDiscovery struct {
+	name string
+}
+
+func (d *dnsDiscovery) Discover(ctx stdctx.Context) ([]Endpoint, error) {
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, d.name)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make([]Endpoint, 0, len(ips))
+	for _, ip := range ips {
+		endpoints = append(endpoints, Endpoint{IP: ip.IP})
+	}
+	return endpoints, nil
+}
+
+// srvDiscovery resolves the SRV records of a name and the addresses of every
+// target, the port is taken from the SRV record
+type srvDiscovery struct {
+	name string
+}
+
+func (d *srvDiscovery) Discover(ctx stdctx.Context) ([]Endpoint, error) {
+	_, srvs, err := net.DefaultResolver.LookupSRV(ctx, "", "", d.name)
+	if err != nil {
+		return nil, err
+	}
+	var endpoints []Endpoint
+	for _, srv := range srvs {
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, srv.Target)
+		if err != nil {
+			logger.DiscoveryLog.Warnf("resolve SRV target %s error %+v", srv.Target, err)
+			continue
+		}
+		for _, ip := range ips {
+			endpoints = append(endpoints, Endpoint{IP: ip.IP, Port: int(srv.Port)})
+		}
+	}
+	if len(endpoints) == 0 && len(srvs) != 0 {
+		return nil, fmt.Errorf("no SRV target of %s could be resolved", d.name)
+	}
+	return endpoints, nil
+}
+
+// staticDiscovery always returns the configured addresses
+type staticDiscovery struct {
+	endpoints []Endpoint
+}
+
+func (d *staticDiscovery) Discover(ctx stdctx.Context) ([]Endpoint, error) {
+	return d.endpoints, nil
+}
+
+// BackendFile is the content of a file discovery source, either in YAML or JSON
+//
+//	backends:
+//	  - address: 10.0.0.1
+//	    port: 5000
+type BackendFile struct {
+	Backends []BackendEntry `yaml:"backends" json:"backends"`
+}
+
+type BackendEntry struct {
+	Address string `yaml:"address" json:"address"`
+	Port    int    `yaml:"port,omitempty" json:"port,omitempty"`
+	Weight  int    `yaml:"weight,omitempty" json:"weight,omitempty"`
+}
+
+// fileDiscovery reads the backend list from a file and re-reads it only when
+// its modification time or size changes
+type fileDiscovery struct {
+	path      string
+	mu        sync.Mutex
+	modTime   time.Time
+	size      int64
+	endpoints []Endpoint
+}
+
+func (d *fileDiscovery) Discover(ctx stdctx.Context) ([]Endpoint, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return nil, err
+	}
+	if d.endpoints != nil && info.ModTime().Equal(d.modTime) && info.Size() == d.size {
+		return d.endpoints, nil
+	}
+	content, err := os.ReadFile(d.path)
+	if err != nil {
+		return nil, err
+	}
+	// YAML is a superset of JSON, so both formats are handled here
+	var file BackendFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("parse backend file %s: %w", d.path, err)
+	}
+	endpoints := make([]Endpoint, 0, len(file.Backends))
+	for _, entry := range file.Backends {
+		ep, err := parseEndpoint(entry.Address)
+		if err != nil {
+			return nil, fmt.Errorf("parse backend file %s: %w", d.path, err)
+		}
+		if entry.Port != 0 {
+			ep.Port = entry.Port
+		}
+		ep.Weight = entry.Weight
+		endpoints = append(endpoints, ep)
+	}
+	logger.DiscoveryLog.Infof("loaded %d backends from %s", len(endpoints), d.path)
+	d.modTime = info.ModTime()
+	d.size = info.Size()
+	d.endpoints = endpoints
+	return endpoints, nil
+}
+
+// discoveryWorker periodically discovers the backends of a single service and
+// reconciles the backends it created against the latest results: new
+// endpoints are handed to add, backends whose endpoint disappeared to remove.
+// Each service runs its own worker, so a slow or failing service does not hold
+// up the others.
+type discoveryWorker struct {
+	name      string
+	discovery Discovery
+	ipFamily  string
+	weight    int
+	// port is the port of the endpoints discovered without one
+	port    int
+	refresh time.Duration
+	retry   backoff
+	add     func(Endpoint) context.NF
+	remove  func(context.NF)
+	owned   map[string]context.NF
+}
+
+func newDiscoveryWorker(svc config.Service, port int, add func(Endpoint) context.NF, remove func(context.NF)) (*discoveryWorker, error) {
+	d, err := NewDiscovery(svc)
+	if err != nil {
+		return nil, err
+	}
+	switch svc.IpFamily {
+	case "", IpFamilyV4, IpFamilyV6:
+	default:
+		return nil, fmt.Errorf("unsupported ipFamily: %s", svc.IpFamily)
+	}
+	w := &discoveryWorker{
+		name:      serviceName(svc),
+		discovery: d,
+		ipFamily:  svc.IpFamily,
+		weight:    svc.Weight,
+		port:      port,
+		refresh:   svc.RefreshInterval,
+		add:       add,
+		remove:    remove,
+	}
+	if w.refresh <= 0 {
+		w.refresh = defaultRefreshInterval
+	}
+	maxRetry := svc.MaxRetryInterval
+	if maxRetry <= 0 {
+		maxRetry = defaultMaxRetryInterval
+	}
+	w.retry = backoff{initial: w.refresh, max: maxRetry, multiplier: 2, jitter: 0.2}
+	return w, nil
+}
+
+func (w *discoveryWorker) run(ctx stdctx.Context) {
+	for {
+		delay := w.refresh
+		logger.DiscoveryLog.Debugln("discover Service", w.name)
+		endpoints, err := w.discovery.Discover(ctx)
+		if err != nil {
+			// keep the current backends, a failed lookup says nothing about them
+			delay = w.retry.next()
+			logger.DiscoveryLog.Warnf("discover Service %s error %+v, retry in %v", w.name, err, delay)
+		} else {
+			w.retry.reset()
+			w.reconcile(filterIpFamily(endpoints, w.ipFamily))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (w *discoveryWorker) reconcile(endpoints []Endpoint) {
+	if w.owned == nil {
+		w.owned = make(map[string]context.NF)
+	}
+	current := make(map[string]struct{}, len(endpoints))
+	for _, ep := range endpoints {
+		// keyed on the port the backend is created with, so an endpoint
+		// discovered with and without the default port is one backend
+		if ep.Port == 0 {
+			ep.Port = w.port
+		}
+		key := ep.String()
+		current[key] = struct{}{}
+		logger.DiscoveryLog.Debugf("discover Service %s, endpoint %s", w.name, key)
+		if ep.Weight <= 0 {
+			ep.Weight = w.weight
+		}
+		if nf, ok := w.owned[key]; ok && backendExists(nf) {
+			continue
+		}
+		delete(w.owned, key)
+		if nf := w.add(ep); nf != nil {
+			w.owned[key] = nf
+		}
+	}
+	for key, nf := range w.owned {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		logger.DiscoveryLog.Infof("endpoint %s is no longer returned by Service %s", key, w.name)
+		delete(w.owned, key)
+		w.remove(nf)
+	}
+}
+
+func backendExists(nf context.NF) bool {
+	ctx := context.Sctplb_Self()
+	ctx.Lock()
+	defer ctx.Unlock()
+	return ctx.HasNF(nf)
+}
+
+func serviceName(svc config.Service) string {
+	switch {
+	case svc.Uri != "":
+		return svc.Uri
+	case svc.File != "":
+		return svc.File
+	default:
+		return fmt.Sprintf("%s%v", svc.Discovery, svc.Addresses)
+	}
+}
@@ -0,0 +1,219 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	stdctx "context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/context"
+)
+
+func Test_StaticDiscovery(t *testing.T) {
+	d, err := NewDiscovery(config.Service{
+		Discovery: DiscoveryStatic,
+		Addresses: []string{"10.0.0.1", "10.0.0.2:5001", "[2001:db8::1]:5002"},
+	})
+	if err != nil {
+		t.Fatalf("NewDiscovery failed: %v", err)
+	}
+	endpoints, err := d.Discover(stdctx.Background())
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	want := []string{"10.0.0.1:0", "10.0.0.2:5001", "[2001:db8::1]:5002"}
+	if len(endpoints) != len(want) {
+		t.Fatalf("endpoint count mismatch. got = %d, want = %d", len(endpoints), len(want))
+	}
+	for i, ep := range endpoints {
+		if ep.String() != want[i] {
+			t.Errorf("endpoint %d mismatch. got = %q, want = %q", i, ep.String(), want[i])
+		}
+	}
+}
+
+func Test_NewDiscoveryInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		svc  config.Service
+	}{
+		{name: "dns without uri", svc: config.Service{}},
+		{name: "srv without uri", svc: config.Service{Discovery: DiscoverySRV}},
+		{name: "static without addresses", svc: config.Service{Discovery: DiscoveryStatic}},
+		{name: "static with hostname", svc: config.Service{Discovery: DiscoveryStatic, Addresses: []string{"amf:5000"}}},
+		{name: "file without path", svc: config.Service{Discovery: DiscoveryFile}},
+		{name: "unknown type", svc: config.Service{Discovery: "consul", Uri: "amf"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewDiscovery(tt.svc); err == nil {
+				t.Errorf("NewDiscovery(%+v) expected error", tt.svc)
+			}
+		})
+	}
+}
+
+func Test_DispatchAddServerInvalid(t *testing.T) {
+	b := BackendSvc{Cfg: config.Config{Configuration: &config.Configuration{
+		Services: []config.Service{
+			{Discovery: DiscoveryStatic, Addresses: []string{"10.0.0.1"}},
+			{Discovery: DiscoveryStatic},
+		},
+	}}}
+	if err := b.DispatchAddServer(); err == nil {
+		t.Errorf("DispatchAddServer with an invalid service expected error")
+	}
+}
+
+func Test_FileDiscovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends.yaml")
+	if err := os.WriteFile(path, []byte("backends:\n  - address: 10.0.0.1\n    port: 5000\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDiscovery(config.Service{Discovery: DiscoveryFile, File: path})
+	if err != nil {
+		t.Fatalf("NewDiscovery failed: %v", err)
+	}
+	endpoints, err := d.Discover(stdctx.Background())
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if len(endpoints) != 1 || endpoints[0].String() != "10.0.0.1:5000" {
+		t.Errorf("yaml endpoints mismatch. got = %v", endpoints)
+	}
+
+	// rewrite the file as JSON, the new content must be picked up
+	content := `{"backends": [{"address": "10.0.0.2"}, {"address": "10.0.0.3", "port": 5001}]}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	endpoints, err = d.Discover(stdctx.Background())
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if len(endpoints) != 2 || endpoints[0].String() != "10.0.0.2:0" || endpoints[1].String() != "10.0.0.3:5001" {
+		t.Errorf("json endpoints mismatch. got = %v", endpoints)
+	}
+}
+
+type fakeDiscovery struct {
+	endpoints []Endpoint
+	err       error
+}
+
+func (d *fakeDiscovery) Discover(ctx stdctx.Context) ([]Endpoint, error) {
+	return d.endpoints, d.err
+}
+
+func Test_DiscoveryWorkersIndependent(t *testing.T) {
+	ctx, cancel := stdctx.WithCancel(stdctx.Background())
+	defer cancel()
+
+	found := make(chan Endpoint, 1)
+	failing := &discoveryWorker{
+		name:      "failing",
+		discovery: &fakeDiscovery{err: os.ErrNotExist},
+		refresh:   time.Hour,
+		retry:     backoff{initial: time.Hour},
+		add: func(Endpoint) context.NF {
+			t.Error("failing service must not add endpoints")
+			return nil
+		},
+	}
+	healthy := &discoveryWorker{
+		name:      "healthy",
+		discovery: &fakeDiscovery{endpoints: []Endpoint{{Port: 5000}}},
+		refresh:   time.Hour,
+		add: func(ep Endpoint) context.NF {
+			found <- ep
+			return nil
+		},
+	}
+	go failing.run(ctx)
+	go healthy.run(ctx)
+
+	select {
+	case ep := <-found:
+		if ep.Port != 5000 {
+			t.Errorf("endpoint mismatch. got = %v", ep)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("healthy service was not discovered")
+	}
+}
+
+func Test_DiscoveryReconcile(t *testing.T) {
+	ctx := context.Sctplb_Self()
+	var removed []context.NF
+	w := &discoveryWorker{
+		name: "reconcile",
+		port: 5000,
+		add: func(ep Endpoint) context.NF {
+			nf := &GrpcServer{address: ep.IP.String(), port: ep.Port}
+			ctx.Lock()
+			ctx.AddNF(nf)
+			ctx.Unlock()
+			return nf
+		},
+		remove: func(nf context.NF) {
+			deleteBackendNF(nf)
+			removed = append(removed, nf)
+		},
+	}
+	a, _ := parseEndpoint("10.1.0.1:5000")
+	b, _ := parseEndpoint("10.1.0.2:5000")
+
+	w.reconcile([]Endpoint{a, b})
+	if len(w.owned) != 2 {
+		t.Fatalf("owned backends mismatch. got = %d, want = 2", len(w.owned))
+	}
+	kept := w.owned[a.String()]
+
+	w.reconcile([]Endpoint{a})
+	if len(removed) != 1 || removed[0].(*GrpcServer).address != "10.1.0.2" {
+		t.Fatalf("removed backends mismatch. got = %v", removed)
+	}
+	if w.owned[a.String()] != kept {
+		t.Errorf("backend of a kept endpoint was replaced")
+	}
+
+	// a backend that dropped out of the pool on its own is added again
+	deleteBackendNF(kept)
+	w.reconcile([]Endpoint{a})
+	if w.owned[a.String()] == kept || !backendExists(w.owned[a.String()]) {
+		t.Errorf("backend of endpoint %s was not added again", a)
+	}
+
+	// an endpoint without a port is the one on the default port
+	w.reconcile([]Endpoint{{IP: a.IP}, a})
+	if len(w.owned) != 1 || len(removed) != 1 {
+		t.Errorf("endpoint without a port is another backend. owned = %d, removed = %d", len(w.owned), len(removed))
+	}
+	deleteBackendNF(w.owned[a.String()])
+}
+
+func Test_FilterIpFamily(t *testing.T) {
+	endpoints, err := parseEndpoints([]string{"10.0.0.1", "2001:db8::1", "::ffff:10.0.0.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := filterIpFamily(endpoints, ""); len(got) != 3 {
+		t.Errorf("dual-stack endpoints mismatch. got = %v", got)
+	}
+	if got := filterIpFamily(endpoints, IpFamilyV4); len(got) != 2 || !got[1].IP.Equal(endpoints[2].IP) {
+		t.Errorf("ipv4 endpoints mismatch. got = %v", got)
+	}
+	if got := filterIpFamily(endpoints, IpFamilyV6); len(got) != 1 || got[0].String() != "[2001:db8::1]:0" {
+		t.Errorf("ipv6 endpoints mismatch. got = %v", got)
+	}
+}
@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"time"
+
+	"github.com/omec-project/sctplb/context"
+	"github.com/omec-project/sctplb/logger"
+)
+
+// defaultDrainTimeout bounds how long a draining backend keeps the messages
+// of its UEs when it does not report that it is empty
+const defaultDrainTimeout = 5 * time.Minute
+
+// drain is called when the backend asks for no new UEs. It leaves the
+// scheduling of InitialUEMessage and non-UE signalling, the messages of the
+// UEs it already serves keep coming until it reports DRAIN_COMPLETE or the
+// drain timeout expires.
+func (b *GrpcServer) drain() {
+	if b.State() != context.NFReady || !b.setState(context.NFDraining, "drain requested by backend") {
+		return
+	}
+	logger.GrpcLog.Infof("server %v is draining", b.address)
+	b.drained.Store(false)
+	timeout := b.drainTimeout
+	if timeout <= 0 {
+		timeout = defaultDrainTimeout
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.drainTimer != nil {
+		b.drainTimer.Stop()
+	}
+	b.drainTimer = time.AfterFunc(timeout, func() {
+		b.drainComplete("drain timeout expired")
+	})
+}
+
+// drainComplete stops routing the UEs of a draining backend to it, they are
+// scheduled on the remaining backends from then on
+func (b *GrpcServer) drainComplete(reason string) {
+	if b.State() != context.NFDraining || b.drained.Swap(true) {
+		return
+	}
+	b.mu.Lock()
+	if b.drainTimer != nil {
+		b.drainTimer.Stop()
+		b.drainTimer = nil
+	}
+	b.mu.Unlock()
+	ueOwners.releaseBackend(b)
+	logger.GrpcLog.Infof("server %v is drained: %s", b.address, reason)
+}
+
+// undrain makes a draining backend take new UEs again
+func (b *GrpcServer) undrain() {
+	b.resetDrain()
+	if b.closing.Load() || b.State() != context.NFDraining {
+		return
+	}
+	if b.setState(context.NFReady, "drain cancelled by backend") {
+		logger.GrpcLog.Infof("server %v is no longer draining", b.address)
+	}
+}
+
+// resetDrain forgets a drain of a previous session
+func (b *GrpcServer) resetDrain() {
+	b.mu.Lock()
+	if b.drainTimer != nil {
+		b.drainTimer.Stop()
+		b.drainTimer = nil
+	}
+	b.mu.Unlock()
+	b.drained.Store(false)
+}
+
+// Drained reports whether the backend finished draining
+func (b *GrpcServer) Drained() bool {
+	return b.drained.Load()
+}
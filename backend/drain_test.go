@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/omec-project/sctplb/context"
+)
+
+func readyGrpcServer(address string) *GrpcServer {
+	b := &GrpcServer{address: address}
+	b.setState(context.NFConnecting, "test")
+	b.setState(context.NFHandshaking, "test")
+	b.setState(context.NFReady, "test")
+	return b
+}
+
+func Test_Drain(t *testing.T) {
+	b := readyGrpcServer("10.3.0.1")
+	b.drainTimeout = time.Hour
+	ran := &context.Ran{}
+	m := mustDecodeNgap(t, uplinkNASTransport(t, 200, 1))
+	b.learnUeOwner(ran, m)
+	defer ueOwners.releaseRan(ran)
+
+	b.drain()
+	if b.State() != context.NFDraining {
+		t.Fatalf("state mismatch. got = %v, want = %v", b.State(), context.NFDraining)
+	}
+	if got, _ := ueBackend(ran, m); got != b {
+		t.Errorf("UE of a draining backend must stay on it. got = %v", got)
+	}
+
+	b.undrain()
+	if b.State() != context.NFReady {
+		t.Fatalf("state after undrain mismatch. got = %v, want = %v", b.State(), context.NFReady)
+	}
+
+	b.drain()
+	b.drainComplete("test")
+	if !b.Drained() {
+		t.Fatal("backend was not drained")
+	}
+	if got, owned := ueBackend(ran, m); got != nil || owned {
+		t.Errorf("UE of a drained backend must be left to the scheduler. got = %v", got)
+	}
+}
+
+func Test_DrainTimeout(t *testing.T) {
+	b := readyGrpcServer("10.3.0.2")
+	b.drainTimeout = 10 * time.Millisecond
+	b.drain()
+	deadline := time.Now().Add(time.Second)
+	for !b.Drained() {
+		if time.Now().After(deadline) {
+			t.Fatal("backend was not drained after the drain timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if b.State() != context.NFDraining {
+		t.Errorf("state mismatch. got = %v, want = %v", b.State(), context.NFDraining)
+	}
+}
@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"time"
+
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/context"
+	"github.com/omec-project/sctplb/logger"
+)
+
+const (
+	defaultFailoverAttempts = 3
+	defaultUnhealthyFor     = 5 * time.Second
+)
+
+var (
+	// failoverAttempts bounds the backends a message is offered to
+	failoverAttempts = defaultFailoverAttempts
+	// unhealthyFor is how long a backend that failed a send is passed over
+	// by the scheduler
+	unhealthyFor = defaultUnhealthyFor
+)
+
+// SetFailover configures the failover of messages a backend could not take,
+// it has to be called before the SCTP service is started
+func SetFailover(cfg *config.Failover) {
+	failoverAttempts, unhealthyFor = defaultFailoverAttempts, defaultUnhealthyFor
+	if cfg == nil {
+		return
+	}
+	if cfg.Attempts > 0 {
+		failoverAttempts = cfg.Attempts
+	}
+	if cfg.UnhealthyFor > 0 {
+		unhealthyFor = cfg.UnhealthyFor
+	}
+}
+
+// healthChecked is implemented by backends that are passed over for a while
+// after they failed to take a message
+type healthChecked interface {
+	Healthy() bool
+	sendFailed(err error, failover bool)
+}
+
+// sendFailed records that the backend could not take a message, failover is
+// set when the message was offered to another backend
+func (b *GrpcServer) sendFailed(err error, failover bool) {
+	b.sendFailures.Add(1)
+	if failover {
+		b.failovers.Add(1)
+	}
+	now := time.Now()
+	b.breaker.record(false, now)
+	b.unhealthyUntil.Store(now.Add(unhealthyFor).UnixNano())
+	logger.DispatchLog.Warnf("server %v failed a send and is unhealthy for %v: %v", b.address, unhealthyFor, err)
+}
+
+// Healthy reports whether the backend took the messages sent to it lately
+// and its circuit breaker is not open
+func (b *GrpcServer) Healthy() bool {
+	now := time.Now()
+	return now.UnixNano() >= b.unhealthyUntil.Load() && b.breaker.allows(now)
+}
+
+// eligibleBackends returns the ready backend NFs the scheduler may pick: the
+// healthy ones, or every ready one when none is healthy
+func eligibleBackends() []context.NF {
+	ready := readyBackends()
+	var healthy []context.NF
+	for _, nf := range ready {
+		if h, ok := nf.(healthChecked); !ok || h.Healthy() {
+			healthy = append(healthy, nf)
+		}
+	}
+	if len(healthy) == 0 {
+		return ready
+	}
+	return healthy
+}
+
+// sendWithFailover sends a message of ran that is not bound to a backend to
+// backend, the scheduler's choice. When the send fails it is offered to the
+// other eligible backends, up to failoverAttempts backends in total.
+func sendWithFailover(backend Backend, ran *context.Ran, send func(Backend) error) error {
+	tried := map[Backend]struct{}{}
+	var err error
+	for attempt := 1; ; attempt++ {
+		tried[backend] = struct{}{}
+		if err = send(backend); err == nil {
+			return nil
+		}
+		next := Backend(nil)
+		if attempt < failoverAttempts {
+			next = failoverBackend(ran, tried)
+		}
+		if h, ok := backend.(healthChecked); ok {
+			h.sendFailed(err, next != nil)
+		}
+		if next == nil {
+			return err
+		}
+		logger.DispatchLog.Infof("failing over message of gNB %v after: %v", ran.RanID(), err)
+		backend = next
+	}
+}
+
+// failoverBackend returns the eligible backend the scheduler picks among the
+// ones not tried yet, nil when every one was tried
+func failoverBackend(ran *context.Ran, tried map[Backend]struct{}) Backend {
+	var backends []context.NF
+	for _, nf := range eligibleBackends() {
+		if _, ok := tried[nf]; !ok {
+			backends = append(backends, nf)
+		}
+	}
+	if len(backends) == 0 {
+		return nil
+	}
+	return scheduler.Select(backends, ran)
+}
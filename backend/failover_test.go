@@ -0,0 +1,128 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/omec-project/ngap/v2/ngapType"
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/context"
+	"github.com/omec-project/sctplb/logger"
+	gClient "github.com/omec-project/sctplb/sdcoreAmfServer"
+)
+
+// failoverBackends adds ready backends with a single slot send queue to the
+// pool, the first one's queue is full
+func failoverBackends(t *testing.T, addresses ...string) []*GrpcServer {
+	t.Helper()
+	ctx := context.Sctplb_Self()
+	var backends []*GrpcServer
+	for _, address := range addresses {
+		b := readyGrpcServer(address)
+		b.queue = newSendQueue(&config.SendQueue{Depth: 1, Overflow: OverflowReject})
+		ctx.AddNF(b)
+		t.Cleanup(func() { ctx.DeleteNF(b) })
+		backends = append(backends, b)
+	}
+	if err := backends[0].queue.push(&gClient.SctplbMessage{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	return backends
+}
+
+func Test_SendWithFailover(t *testing.T) {
+	defer func(s Scheduler) { scheduler = s }(scheduler)
+	scheduler = &roundRobinScheduler{}
+	backends := failoverBackends(t, "10.8.0.1", "10.8.0.2", "10.8.0.3")
+	ran := &context.Ran{Log: logger.RanLog}
+	ran.SetRanId("gnb-8")
+	send := func(b Backend) error {
+		return b.Send([]byte{1}, false, ran, nil)
+	}
+
+	if err := sendWithFailover(backends[0], ran, send); err != nil {
+		t.Fatalf("sendWithFailover failed: %v", err)
+	}
+	if backends[0].sendFailures.Load() != 1 || backends[0].failovers.Load() != 1 || backends[0].Healthy() {
+		t.Errorf("failed backend mismatch. failures = %d, failovers = %d, healthy = %v",
+			backends[0].sendFailures.Load(), backends[0].failovers.Load(), backends[0].Healthy())
+	}
+	if backends[1].Outstanding()+backends[2].Outstanding() != 1 {
+		t.Errorf("message was not failed over")
+	}
+	for _, nf := range eligibleBackends() {
+		if nf == backends[0] {
+			t.Errorf("unhealthy backend is eligible")
+		}
+	}
+
+	SetFailover(&config.Failover{Attempts: 1})
+	defer SetFailover(nil)
+	if err := sendWithFailover(backends[0], ran, send); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("send without failover error mismatch. got = %v, want = %v", err, ErrQueueFull)
+	}
+	if backends[0].failovers.Load() != 1 {
+		t.Errorf("failover recorded beyond the attempts")
+	}
+}
+
+func Test_RouteMessageKeepsUeOwner(t *testing.T) {
+	backends := failoverBackends(t, "10.8.0.4", "10.8.0.5")
+	ran := &context.Ran{Log: logger.RanLog}
+	ran.SetRanId("gnb-9")
+	m := mustDecodeNgap(t, uplinkNASTransport(t, 300, 1))
+	backends[0].learnUeOwner(ran, m)
+	defer ueOwners.releaseRan(ran)
+
+	if !routeMessage(ran, uplinkNASTransport(t, 300, 1), m, nil) {
+		t.Fatal("message of an owned UE was not routed")
+	}
+	if backends[1].Outstanding() != 0 {
+		t.Errorf("message of an owned UE was failed over")
+	}
+	if backends[0].sendFailures.Load() != 1 {
+		t.Errorf("send failure of the owner was not recorded")
+	}
+}
+
+func Test_RouteMessageReconnectingUeOwner(t *testing.T) {
+	backends := failoverBackends(t, "10.8.0.6", "10.8.0.7")
+	local, remote := net.Pipe()
+	defer remote.Close()
+	ran := &context.Ran{Conn: local, Log: logger.RanLog}
+	received := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 1024)
+		if n, err := remote.Read(buf); err == nil {
+			received <- bytes.Clone(buf[:n])
+		}
+	}()
+	msg := uplinkNASTransport(t, 300, 1)
+	m := mustDecodeNgap(t, msg)
+	backends[1].learnUeOwner(ran, m)
+	defer ueOwners.releaseRan(ran)
+
+	// the messages of the UE are answered while its AMF reconnects
+	backends[1].setState(context.NFFailed, "test")
+	if !routeMessage(ran, msg, m, nil) {
+		t.Fatal("message of a UE whose AMF reconnects was held")
+	}
+	select {
+	case got := <-received:
+		if !mustDecodeNgap(t, got).initiating(ngapType.InitiatingMessagePresentErrorIndication) {
+			t.Errorf("message was not answered with an Error Indication")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("gNB got no answer")
+	}
+	if backends[0].Outstanding() != 1 || backends[1].Outstanding() != 0 {
+		t.Errorf("message of a UE whose AMF reconnects was sent to another AMF")
+	}
+}
@@ -5,116 +5,368 @@
 package backend
 
 import (
+	"bytes"
 	ctxt "context"
-	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/ishidawataru/sctp"
+	"github.com/omec-project/sctplb/config"
 	"github.com/omec-project/sctplb/context"
 	"github.com/omec-project/sctplb/logger"
 	gClient "github.com/omec-project/sctplb/sdcoreAmfServer"
 	"google.golang.org/grpc"
+	grpcbackoff "google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/connectivity"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 )
 
+// backendDrainTimeout bounds how long a removed backend may take to close its
+// side of the stream
+const backendDrainTimeout = 5 * time.Second
+
+const (
+	defaultReconnectInitial    = 500 * time.Millisecond
+	defaultReconnectMax        = 30 * time.Second
+	defaultReconnectMultiplier = 2.0
+	defaultReconnectJitter     = 0.2
+	defaultMaxRetryWindow      = 5 * time.Minute
+)
+
+func newGrpcServer(address string, port int, cfg *config.Configuration) *GrpcServer {
+	b := &GrpcServer{
+		address:  address,
+		port:     port,
+		queue:    newSendQueue(cfg.SendQueue),
+		dialOpts: dialOptions(cfg),
+		reconnect: backoff{
+			initial:    defaultReconnectInitial,
+			max:        defaultReconnectMax,
+			multiplier: defaultReconnectMultiplier,
+			jitter:     defaultReconnectJitter,
+		},
+		retryWindow:      defaultMaxRetryWindow,
+		drainTimeout:     cfg.DrainTimeout,
+		handshakeTimeout: defaultHandshakeTimeout,
+		load:             backendLoad{ttl: cfg.LoadReportTTL},
+		stop:             make(chan struct{}),
+		done:             make(chan struct{}),
+	}
+	if rc := cfg.Reconnect; rc != nil {
+		if rc.InitialInterval > 0 {
+			b.reconnect.initial = rc.InitialInterval
+		}
+		if rc.MaxInterval > 0 {
+			b.reconnect.max = rc.MaxInterval
+		}
+		if rc.Multiplier >= 1 {
+			b.reconnect.multiplier = rc.Multiplier
+		}
+		if rc.Jitter > 0 && rc.Jitter <= 1 {
+			b.reconnect.jitter = rc.Jitter
+		}
+		if rc.MaxRetryWindow > 0 {
+			b.retryWindow = rc.MaxRetryWindow
+		}
+	}
+	if cfg.HandshakeTimeout > 0 {
+		b.handshakeTimeout = cfg.HandshakeTimeout
+	}
+	return b
+}
+
+// dialOptions returns the options the backend connections are created with
+func dialOptions(cfg *config.Configuration) []grpc.DialOption {
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(transportCredentials(cfg.TLS))}
+	g := cfg.Grpc
+	if g == nil {
+		return opts
+	}
+	if g.KeepaliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                g.KeepaliveTime,
+			Timeout:             g.KeepaliveTimeout,
+			PermitWithoutStream: g.PermitWithoutStream,
+		}))
+	}
+	if g.InitialWindowSize > 0 {
+		opts = append(opts, grpc.WithInitialWindowSize(g.InitialWindowSize))
+	}
+	if g.InitialConnWindowSize > 0 {
+		opts = append(opts, grpc.WithInitialConnWindowSize(g.InitialConnWindowSize))
+	}
+	var callOpts []grpc.CallOption
+	if g.MaxRecvMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(g.MaxRecvMsgSize))
+	}
+	if g.MaxSendMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallSendMsgSize(g.MaxSendMsgSize))
+	}
+	if len(callOpts) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+	if g.ConnectTimeout > 0 {
+		opts = append(opts, grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           grpcbackoff.DefaultConfig,
+			MinConnectTimeout: g.ConnectTimeout,
+		}))
+	}
+	return opts
+}
+
+// ConnectToServer supervises the backend: it opens the stream, handshakes and
+// serves it, and whenever the stream fails it reconnects with exponential
+// backoff. The backend stays in the pool, but not ready, while it reconnects
+// and is only deleted once it could not be reached for longer than the retry
+// window, or when it is closed.
 func (b *GrpcServer) ConnectToServer(port int) {
-	target := fmt.Sprintf("%s:%d", b.address, port)
+	defer close(b.done)
+	target := net.JoinHostPort(b.address, strconv.Itoa(port))
 
 	logger.AppLog.Infoln("connecting to target", target)
 
-	var err error
-	b.conn, err = grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(target, b.dialOpts...)
 	if err != nil {
 		logger.AppLog.Errorln("did not connect:", err)
+		b.setState(context.NFClosed, err.Error())
 		deleteBackendNF(b)
 		return
 	}
+	b.mu.Lock()
+	b.conn = conn
+	b.mu.Unlock()
+
+	b.gc = gClient.NewNgapServiceClient(conn)
+	go b.connectionOnState(conn)
+
+	var failingSince time.Time
+	for !b.closing.Load() {
+		if b.serve() {
+			failingSince = time.Time{}
+			b.reconnect.reset()
+		}
+		if b.closing.Load() {
+			return
+		}
+		if failingSince.IsZero() {
+			failingSince = time.Now()
+		}
+		if time.Since(failingSince) > b.retryWindow {
+			logger.GrpcLog.Errorf("server %v unreachable for more than %v, removing it", b.address, b.retryWindow)
+			// closing waits for this goroutine to return
+			removeBackend(b)
+			return
+		}
+		delay := b.reconnect.next()
+		logger.GrpcLog.Infof("reconnecting to server %v in %v", b.address, delay)
+		select {
+		case <-b.stop:
+			return
+		case <-time.After(delay):
+		}
+	}
+}
 
-	b.gc = gClient.NewNgapServiceClient(b.conn)
+// serve runs one session on a new stream until the stream fails, it reports
+// whether the session got ready
+func (b *GrpcServer) serve() bool {
+	sessionCtx, cancel := ctxt.WithCancel(ctxt.Background())
+	defer cancel()
+	b.mu.Lock()
+	b.cancelSession = cancel
+	b.sessionDone = sessionCtx.Done()
+	b.mu.Unlock()
 
-	stream, err := b.gc.HandleMessage(ctxt.Background())
+	b.resetDrain()
+	b.capabilities.Store(0)
+	b.load.reset()
+	b.overload.stop()
+	b.setState(context.NFConnecting, "opening stream")
+	stream, err := b.gc.HandleMessage(sessionCtx)
 	if err != nil {
-		logger.AppLog.Errorw("open stream error", err)
-		deleteBackendNF(b)
-		return
+		logger.AppLog.Errorw("open stream error", "error", err)
+		b.setState(context.NFFailed, err.Error())
+		return false
+	}
+	b.setState(context.NFHandshaking, "stream opened")
+	if err := b.handshake(stream); err != nil {
+		logger.GrpcLog.Errorf("handshake with server %v failed: %v", b.address, err)
+		b.setState(context.NFFailed, "handshake failed: "+err.Error())
+		return false
 	}
+	// a message that slipped in while the previous session ended is not
+	// sent to a backend that may have lost its UE contexts since
+	b.discardQueue()
+	if !b.setState(context.NFReady, "handshake completed") {
+		return false
+	}
+	// reconnecting is not counted against the breaker, a backend is
+	// scheduled as soon as it is ready again
+	b.breaker.reset(time.Now())
+	logger.GrpcLog.Infof("server %v is ready", b.address)
+	go b.writeToServer(sessionCtx, stream)
+	b.readFromServer(stream)
+	if !b.closing.Load() {
+		b.setState(context.NFFailed, "stream ended")
+		// wakes the senders blocked on the queue and stops the writer
+		cancel()
+		b.discardQueue()
+	}
+	return true
+}
 
-	b.stream = stream
-	b.state = true
+// discardQueue drops the messages queued for a session that ended, they are
+// not replayed on the next session
+func (b *GrpcServer) discardQueue() {
+	if n := b.queue.discard(); n > 0 {
+		logger.GrpcLog.Warnf("dropped %d messages queued for server %v", n, b.address)
+	}
+}
+
+// writeToServer is the only goroutine sending on the stream of a session, it
+// feeds the stream from the send queue until the session ends. Once the
+// backend is closed it flushes the queue and half-closes the stream.
+func (b *GrpcServer) writeToServer(sessionCtx ctxt.Context, stream gClient.NgapService_HandleMessageClient) {
 	for {
-		// INIT message to new NF instance
-		context.Sctplb_Self().RanPool.Range(func(key, value any) bool {
-			req := gClient.SctplbMessage{}
-			req.VerboseMsg = "Hello From SCTP LB!"
-			req.Msgtype = gClient.MsgType_INIT_MSG
-			req.SctplbId = os.Getenv("HOSTNAME")
-			candidate := value.(*context.Ran)
-			if candidate.RanId != nil {
-				req.GnbId = *candidate.RanId
-			} else {
-				logger.AppLog.Infof("ran connection %v is exist without GnbId, so not sending this ran details to NF",
-					candidate.GnbIp)
+		select {
+		case <-sessionCtx.Done():
+			return
+		case <-b.stop:
+			b.flush(stream)
+			if err := stream.CloseSend(); err != nil {
+				logger.GrpcLog.Warnf("close send to server %v: %v", b.address, err)
 			}
-			if err := stream.Send(&req); err != nil {
-				logger.AppLog.Warnln("can not send:", err)
+			return
+		case msg := <-b.queue.ch:
+			if err := stream.Send(msg); err != nil {
+				logger.GrpcLog.Errorf("error in Send %v, ending session with server %v", err, b.address)
+				b.breaker.record(false, time.Now())
+				b.endSession()
+				return
 			}
-			logger.AppLog.Infoln("send Request message")
-			response, err := stream.Recv()
-			if err != nil {
-				logger.AppLog.Errorln("response from server: error", err)
-				b.state = false
-			} else {
-				logger.AppLog.Infof("init Response from Server %s server: %s", response.AmfId, response.VerboseMsg)
-				b.state = true
+			b.breaker.record(true, time.Now())
+			b.queue.sent.Add(1)
+		}
+	}
+}
+
+func (b *GrpcServer) flush(stream gClient.NgapService_HandleMessageClient) {
+	for {
+		select {
+		case msg := <-b.queue.ch:
+			if err := stream.Send(msg); err != nil {
+				logger.GrpcLog.Warnf("flush to server %v failed: %v", b.address, err)
+				return
 			}
-			return true
-		})
-		break
+			b.queue.sent.Add(1)
+		default:
+			return
+		}
+	}
+}
+
+// enqueue hands msg to the writer of the backend
+func (b *GrpcServer) enqueue(msg *gClient.SctplbMessage) error {
+	if b.closing.Load() {
+		return ErrBackendClosed
+	}
+	if state := b.State(); state != context.NFReady && state != context.NFDraining {
+		return ErrBackendNotReady
 	}
-	if b.state {
-		go b.connectionOnState()
-		go b.readFromServer()
+	b.mu.Lock()
+	session := b.sessionDone
+	b.mu.Unlock()
+	return b.queue.push(msg, b.stop, session)
+}
+
+// Weight returns the relative capacity of the backend used by the weighted scheduler
+func (b *GrpcServer) Weight() int {
+	return b.weight
+}
+
+// FreeCapacity returns the free capacity of the backend used by the load-aware
+// scheduler, derived from its load reports
+func (b *GrpcServer) FreeCapacity() float64 {
+	return b.load.freeCapacity(time.Now())
+}
+
+// Outstanding returns the number of messages waiting in the send queue
+func (b *GrpcServer) Outstanding() int {
+	if b.queue == nil {
+		return 0
 	}
+	return len(b.queue.ch)
 }
 
-func (b *GrpcServer) readFromServer() {
+// QueueStats returns the counters of the send queue
+func (b *GrpcServer) QueueStats() QueueStats {
+	return b.queue.stats()
+}
+
+func (b *GrpcServer) readFromServer(stream gClient.NgapService_HandleMessageClient) {
 	for {
-		response, err := b.stream.Recv()
+		response, err := stream.Recv()
 		if err != nil {
+			if b.closing.Load() {
+				logger.GrpcLog.Infof("stream to server %v closed: %v", b.address, err)
+				return
+			}
 			logger.GrpcLog.Errorf("error in Recv %v, Stop listening for this server %v", err, b.address)
-			deleteBackendNF(b)
+			b.breaker.record(false, time.Now())
 			return
 		} else {
+			b.breaker.record(true, time.Now())
+			if !b.supports(gClient.Capability_CAP_STREAM_ID) {
+				response.StreamId, response.Ppid, response.Unordered = nil, 0, false
+			}
 			if response.Msgtype == gClient.MsgType_INIT_MSG {
 				logger.GrpcLog.Infof("init Response from Server %s server: %s", response.AmfId, response.VerboseMsg)
+			} else if response.Msgtype == gClient.MsgType_DRAIN ||
+				response.Msgtype == gClient.MsgType_UNDRAIN ||
+				response.Msgtype == gClient.MsgType_DRAIN_COMPLETE {
+				if !b.supports(gClient.Capability_CAP_DRAIN) {
+					logger.GrpcLog.Warnf("ignoring %v of server %v, drain was not negotiated", response.Msgtype, b.address)
+				} else if response.Msgtype == gClient.MsgType_DRAIN {
+					b.drain()
+				} else if response.Msgtype == gClient.MsgType_UNDRAIN {
+					b.undrain()
+				} else {
+					b.drainComplete("backend reported it is empty")
+				}
+			} else if response.Msgtype == gClient.MsgType_LOAD_REPORT {
+				if !b.supports(gClient.Capability_CAP_LOAD_REPORT) {
+					logger.GrpcLog.Warnf("ignoring load report of server %v, it was not negotiated", b.address)
+				} else {
+					b.reportLoad(response.LoadReport)
+				}
 			} else if response.Msgtype == gClient.MsgType_REDIRECT_MSG {
-				var found bool
-				ctx := context.Sctplb_Self()
-				for _, instance := range ctx.Backends {
-					b1 := instance.(*GrpcServer)
-					if b1.address == response.RedirectId {
-						if !b1.state {
-							logger.GrpcLog.Infoln("backend state is not in READY state, so not forwarding redirected Msg")
-						} else {
-							t := gClient.SctplbMessage{}
-							t.VerboseMsg = "Hello From gNB Message !"
-							t.Msgtype = gClient.MsgType_GNB_MSG
-							t.SctplbId = os.Getenv("HOSTNAME")
-							t.Msg = response.Msg
-							t.GnbId = response.GnbId
-							err := b1.stream.Send(&t)
-							if err != nil {
-								logger.GrpcLog.Infoln("error forwarding msg")
-							}
-							logger.GrpcLog.Infoln("successfully forwarded msg to correct AMF")
-							found = true
-						}
-						break
+				b1 := findBackendByHost(response.RedirectId)
+				if b1 == nil {
+					logger.GrpcLog.Infof("dropping redirected message as backend ip [%v] is not exist", response.RedirectId)
+				} else if b1.State() != context.NFReady {
+					logger.GrpcLog.Infoln("backend state is not in READY state, so not forwarding redirected Msg")
+				} else {
+					t := gClient.SctplbMessage{}
+					t.VerboseMsg = "Hello From gNB Message !"
+					t.Msgtype = gClient.MsgType_GNB_MSG
+					t.SctplbId = os.Getenv("HOSTNAME")
+					t.Msg = response.Msg
+					t.GnbId = response.GnbId
+					if err := b1.enqueue(&t); err != nil {
+						logger.GrpcLog.Infof("error forwarding msg: %v", err)
+					} else {
+						logger.GrpcLog.Infoln("successfully forwarded msg to correct AMF")
 					}
 				}
-				if !found {
-					logger.GrpcLog.Infof("dropping redirected message as backend ip [%v] is not exist", response.RedirectId)
+			} else if response.Addressing == gClient.AddressMode_BROADCAST && b.supports(gClient.Capability_CAP_BROADCAST) {
+				// an overload of the AMF is enforced here rather than
+				// broadcast to the gNBs
+				if m, err := decodeNgap(response.Msg); err == nil && b.handleOverload(m) {
+					continue
 				}
+				broadcast(response)
 			} else {
 				var ran *context.Ran
 				// fetch ran connection based on GnbId
@@ -131,8 +383,16 @@ func (b *GrpcServer) readFromServer() {
 					ran, _ = context.Sctplb_Self().RanFindByGnbId(response.GnbId)
 				}
 				if ran != nil {
-					_, err := ran.Conn.Write(response.Msg)
+					m, err := decodeNgap(response.Msg)
 					if err != nil {
+						ran.Log.Debugf("can not decode downlink NGAP message: %v", err)
+					}
+					if b.handleOverload(m) || setups.handleAnswer(b, ran, response, m) {
+						continue
+					}
+					b.learnUeOwner(ran, m)
+					sites.downlink(ran, m)
+					if err := writeToRan(ran, response, m); err != nil {
 						logger.RanLog.Infof("err %+v", err)
 					}
 				} else {
@@ -143,21 +403,63 @@ func (b *GrpcServer) readFromServer() {
 	}
 }
 
-func (b *GrpcServer) connectionOnState() {
-	go func() {
-		// continue checking for state change
-		// until one of break states is found
-		for {
-			change := b.conn.WaitForStateChange(ctxt.Background(), b.conn.GetState())
-			if change && b.conn.GetState() == connectivity.Idle {
-				deleteBackendNF(b)
-				return
-			}
+// findBackendByHost returns the gRPC backend whose address is the host of target
+func findBackendByHost(target string) *GrpcServer {
+	ctx := context.Sctplb_Self()
+	ctx.Lock()
+	defer ctx.Unlock()
+	for _, instance := range ctx.Backends {
+		if b := instance.(*GrpcServer); sameHost(b.address, target) {
+			return b
+		}
+	}
+	return nil
+}
+
+// sameHost reports whether address and the host part of target, which may be
+// a bare IPv4/IPv6 address or carry a port, denote the same IP address
+func sameHost(address, target string) bool {
+	if host, _, err := net.SplitHostPort(target); err == nil {
+		target = host
+	}
+	a, t := net.ParseIP(address), net.ParseIP(target)
+	if a == nil || t == nil {
+		return address == target
+	}
+	return a.Equal(t)
+}
+
+func (b *GrpcServer) endSession() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cancelSession != nil {
+		b.cancelSession()
+	}
+}
+
+// connectionOnState ends the session as soon as the transport under it is
+// lost, so the supervisor reconnects instead of waiting for the stream to
+// fail. It returns once the connection is shut down.
+func (b *GrpcServer) connectionOnState(conn *grpc.ClientConn) {
+	state := conn.GetState()
+	for conn.WaitForStateChange(ctxt.Background(), state) {
+		state = conn.GetState()
+		switch state {
+		case connectivity.Idle:
+			// the transport went away, e.g. the backend closed it or a
+			// keepalive ping was not answered
+			logger.GrpcLog.Infof("connection to server %v is idle", b.address)
+			b.endSession()
+		case connectivity.TransientFailure:
+			logger.GrpcLog.Warnf("connection to server %v failed", b.address)
+			b.endSession()
+		case connectivity.Shutdown:
+			return
 		}
-	}()
+	}
 }
 
-func (b *GrpcServer) Send(msg []byte, end bool, ran *context.Ran) error {
+func (b *GrpcServer) Send(msg []byte, end bool, ran *context.Ran, info *sctp.SndRcvInfo) error {
 	t := gClient.SctplbMessage{}
 	if end {
 		t.VerboseMsg = "Bye From gNB Message !"
@@ -177,12 +479,96 @@ func (b *GrpcServer) Send(msg []byte, end bool, ran *context.Ran) error {
 			t.GnbId = *ran.RanId
 		} else {
 			t.GnbIpAddr = ran.Conn.RemoteAddr().String()
+			b.rans.Store(ran, struct{}{})
 		}
-		t.Msg = msg
+		// msg is the read buffer of the association, which is reused
+		// before the writer sends the queued message
+		t.Msg = bytes.Clone(msg)
+		if info != nil && b.supports(gClient.Capability_CAP_STREAM_ID) {
+			t.StreamId = uint32(info.Stream)
+			t.Ppid = info.PPID
+			t.Unordered = info.Flags&sctp.SCTP_UNORDERED != 0
+		}
+	}
+	if end && ran != nil {
+		b.rans.Delete(ran)
+	}
+	return b.enqueue(&t)
+}
+
+func (b *GrpcServer) State() context.NFState {
+	return b.state.State()
+}
+
+// Transitions returns the most recent state transitions of the backend
+func (b *GrpcServer) Transitions() []context.NFTransition {
+	return b.state.Transitions()
+}
+
+func (b *GrpcServer) setState(to context.NFState, reason string) bool {
+	return b.state.Transition(b, to, reason)
+}
+
+// Close stops the backend: the writer flushes the send queue and half-closes
+// the stream so the backend can finish in-flight work, the connection is
+// closed once the backend ended the stream as well or backendDrainTimeout
+// expired
+func (b *GrpcServer) Close() {
+	if b.closing.Swap(true) {
+		return
+	}
+	if b.State() == context.NFReady {
+		b.setState(context.NFDraining, "closing")
 	}
-	return b.stream.Send(&t)
+	if b.stop != nil {
+		close(b.stop)
+	}
+	if b.done != nil {
+		select {
+		case <-b.done:
+		case <-time.After(backendDrainTimeout):
+			logger.GrpcLog.Warnf("server %v did not close the stream within %v", b.address, backendDrainTimeout)
+			b.endSession()
+			<-b.done
+		}
+	}
+	b.mu.Lock()
+	conn := b.conn
+	b.mu.Unlock()
+	if conn != nil {
+		if err := conn.Close(); err != nil {
+			logger.GrpcLog.Warnf("close connection to server %v: %v", b.address, err)
+		}
+	}
+	b.setState(context.NFClosed, "closed")
+	logger.GrpcLog.Infof("server %v closed", b.address)
 }
 
-func (b *GrpcServer) State() bool {
-	return b.state
+// handoverRans announces the gNBs that were set up through this backend to
+// every remaining ready backend, so they keep being reachable after it is gone
+func (b *GrpcServer) handoverRans() {
+	ctx := context.Sctplb_Self()
+	b.rans.Range(func(key, value any) bool {
+		ran := key.(*context.Ran)
+		b.rans.Delete(ran)
+		if _, ok := ctx.RanFindByConn(ran.Conn); !ok || ran.RanId == nil {
+			return true
+		}
+		req := gClient.SctplbMessage{}
+		req.VerboseMsg = "Hello From gNB Message !"
+		req.Msgtype = gClient.MsgType_GNB_CONN
+		req.SctplbId = os.Getenv("HOSTNAME")
+		req.GnbId = *ran.RanId
+		req.GnbIpAddr = ran.GnbIp
+		for _, instance := range readyBackends() {
+			target := instance.(*GrpcServer)
+			if err := target.enqueue(&req); err != nil {
+				logger.GrpcLog.Warnf("handover of gNB %v to server %v failed: %v", ran.RanID(), target.address, err)
+				continue
+			}
+			target.rans.Store(ran, struct{}{})
+		}
+		logger.GrpcLog.Infof("handed over gNB %v from server %v", ran.RanID(), b.address)
+		return true
+	})
 }
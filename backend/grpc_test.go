@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	ctxt "context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omec-project/sctplb/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+func Test_SameHost(t *testing.T) {
+	tests := []struct {
+		address string
+		target  string
+		want    bool
+	}{
+		{address: "10.0.0.1", target: "10.0.0.1", want: true},
+		{address: "10.0.0.1", target: "10.0.0.1:5000", want: true},
+		{address: "10.0.0.1", target: "::ffff:10.0.0.1", want: true},
+		{address: "10.0.0.1", target: "10.0.0.2", want: false},
+		{address: "2001:db8::1", target: "2001:db8:0:0:0:0:0:1", want: true},
+		{address: "2001:db8::1", target: "[2001:db8::1]:5000", want: true},
+		{address: "2001:db8::1", target: "2001:db8::2", want: false},
+		{address: "2001:db8::1", target: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := sameHost(tt.address, tt.target); got != tt.want {
+			t.Errorf("sameHost(%q, %q) = %v, want %v", tt.address, tt.target, got, tt.want)
+		}
+	}
+}
+
+func Test_DialOptions(t *testing.T) {
+	if got := len(dialOptions(&config.Configuration{})); got != 1 {
+		t.Errorf("default dial options mismatch. got = %d, want = 1", got)
+	}
+	cfg := &config.Configuration{Grpc: &config.Grpc{
+		KeepaliveTime:         10 * time.Second,
+		KeepaliveTimeout:      time.Second,
+		InitialWindowSize:     1 << 20,
+		InitialConnWindowSize: 1 << 20,
+		MaxRecvMsgSize:        1 << 22,
+		ConnectTimeout:        time.Second,
+	}}
+	if got := len(dialOptions(cfg)); got != 6 {
+		t.Errorf("tuned dial options mismatch. got = %d, want = 6", got)
+	}
+}
+
+func Test_ConnectionOnStateEndsSession(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	go func() { _ = server.Serve(ln) }()
+	defer server.Stop()
+
+	b := &GrpcServer{address: "127.0.0.1", dialOpts: dialOptions(&config.Configuration{})}
+	b.conn, err = grpc.NewClient(ln.Addr().String(), b.dialOpts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ended := make(chan struct{})
+	var once sync.Once
+	b.cancelSession = func() { once.Do(func() { close(ended) }) }
+
+	ctx, cancel := ctxt.WithTimeout(ctxt.Background(), 5*time.Second)
+	defer cancel()
+	b.conn.Connect()
+	for state := b.conn.GetState(); state != connectivity.Ready; state = b.conn.GetState() {
+		if !b.conn.WaitForStateChange(ctx, state) {
+			t.Fatal("connection did not get ready")
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		b.connectionOnState(b.conn)
+		close(done)
+	}()
+
+	// the backend going away must end the session
+	server.Stop()
+	select {
+	case <-ended:
+	case <-ctx.Done():
+		t.Fatal("session was not ended when the transport was lost")
+	}
+	if err := b.conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("connectionOnState did not return on shutdown")
+	}
+}
+
+func Test_CloseWhileConnecting(t *testing.T) {
+	b := newGrpcServer("127.0.0.1", 1, &config.Configuration{})
+	go b.ConnectToServer(1)
+	// a backend removed right after it was added
+	b.Close()
+	select {
+	case <-b.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("backend did not stop connecting")
+	}
+}
@@ -0,0 +1,177 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/omec-project/sctplb/context"
+	"github.com/omec-project/sctplb/logger"
+	gClient "github.com/omec-project/sctplb/sdcoreAmfServer"
+)
+
+const (
+	// protocolVersion is the version of the NgapService protocol spoken by
+	// sctplb, minProtocolVersion the oldest version it still accepts from a
+	// backend that announces one. Backends that do not announce a version
+	// are served with the behaviour of version 0.
+	protocolVersion    = 1
+	minProtocolVersion = 1
+)
+
+// capabilities is the bitmap of the optional protocol behaviours of sctplb
+const capabilities = uint64(gClient.Capability_CAP_STREAM_ID |
+	gClient.Capability_CAP_BROADCAST |
+	gClient.Capability_CAP_DRAIN |
+	gClient.Capability_CAP_LOAD_REPORT |
+	gClient.Capability_CAP_BATCHING)
+
+const defaultHandshakeTimeout = 5 * time.Second
+
+var errHandshakeTimeout = errors.New("handshake timed out")
+
+// handshake introduces sctplb to a new backend: a single INIT_MSG carries the
+// protocol version, the sctplb instance, its capabilities and every known
+// gNB. The backend has to answer with an INIT_MSG within the handshake
+// timeout, otherwise the session is ended and the handshake fails. The
+// capabilities both sides support are enabled for the session, a backend
+// without batching gets every gNB announced in an INIT_MSG of its own.
+func (b *GrpcServer) handshake(stream gClient.NgapService_HandleMessageClient) error {
+	req := &gClient.SctplbMessage{
+		VerboseMsg: "Hello From SCTP LB!",
+		Msgtype:    gClient.MsgType_INIT_MSG,
+		SctplbId:   os.Getenv("HOSTNAME"),
+		Handshake:  newHandshake(),
+	}
+	if err := stream.Send(req); err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	response, err := b.recvInit(stream)
+	if err != nil {
+		return err
+	}
+	logger.GrpcLog.Infof("init Response from Server %s server: %s", response.AmfId, response.VerboseMsg)
+	if err := b.negotiate(response.Handshake); err != nil {
+		return err
+	}
+	if !b.supports(gClient.Capability_CAP_BATCHING) {
+		return b.announceRans(stream)
+	}
+	return nil
+}
+
+// negotiate enables the capabilities shared with the backend that answered
+// the handshake with peer
+func (b *GrpcServer) negotiate(peer *gClient.Handshake) error {
+	var version uint32
+	var peerCapabilities uint64
+	if peer != nil {
+		version, peerCapabilities = peer.Version, peer.Capabilities
+		if version < minProtocolVersion {
+			return fmt.Errorf("unsupported protocol version %d", version)
+		}
+		version = min(version, protocolVersion)
+	}
+	b.version.Store(version)
+	b.capabilities.Store(capabilities & peerCapabilities)
+	logger.GrpcLog.Infof("server %v speaks protocol version %d, capabilities %#x",
+		b.address, version, b.capabilities.Load())
+	return nil
+}
+
+// supports reports whether capability was negotiated with the backend
+func (b *GrpcServer) supports(capability gClient.Capability) bool {
+	return b.capabilities.Load()&uint64(capability) != 0
+}
+
+// announceRans announces every gNB with a GnbId in an INIT_MSG of its own,
+// the way backends without batching learn the gNBs
+func (b *GrpcServer) announceRans(stream gClient.NgapService_HandleMessageClient) error {
+	var err error
+	context.Sctplb_Self().RanPool.Range(func(key, value any) bool {
+		ran := value.(*context.Ran)
+		if ran.RanId == nil {
+			logger.AppLog.Infof("ran connection %v is exist without GnbId, so not sending this ran details to NF",
+				ran.GnbIp)
+			return true
+		}
+		req := &gClient.SctplbMessage{
+			VerboseMsg: "Hello From SCTP LB!",
+			Msgtype:    gClient.MsgType_INIT_MSG,
+			SctplbId:   os.Getenv("HOSTNAME"),
+			GnbId:      *ran.RanId,
+		}
+		if err = stream.Send(req); err != nil {
+			err = fmt.Errorf("send: %w", err)
+			return false
+		}
+		_, err = b.recvInit(stream)
+		return err == nil
+	})
+	return err
+}
+
+// recvInit waits for the INIT_MSG answer of the backend for at most the
+// handshake timeout
+func (b *GrpcServer) recvInit(stream gClient.NgapService_HandleMessageClient) (*gClient.AmfMessage, error) {
+	type result struct {
+		response *gClient.AmfMessage
+		err      error
+	}
+	received := make(chan result, 1)
+	go func() {
+		response, err := stream.Recv()
+		received <- result{response: response, err: err}
+	}()
+	timeout := b.handshakeTimeout
+	if timeout <= 0 {
+		timeout = defaultHandshakeTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var r result
+	select {
+	case r = <-received:
+	case <-timer.C:
+		// ending the session unblocks Recv
+		b.endSession()
+		return nil, errHandshakeTimeout
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("receive: %w", r.err)
+	}
+	if r.response.Msgtype != gClient.MsgType_INIT_MSG {
+		return nil, fmt.Errorf("unexpected %v in answer", r.response.Msgtype)
+	}
+	return r.response, nil
+}
+
+// newHandshake describes sctplb and the gNBs currently associated with it
+func newHandshake() *gClient.Handshake {
+	h := &gClient.Handshake{
+		Version:      protocolVersion,
+		SctplbId:     os.Getenv("HOSTNAME"),
+		Capabilities: capabilities,
+	}
+	context.Sctplb_Self().RanPool.Range(func(key, value any) bool {
+		ran := value.(*context.Ran)
+		gnb := &gClient.GnbInfo{Addresses: []string{ran.GnbIp}}
+		if ran.RanId != nil {
+			gnb.GnbId = *ran.RanId
+		}
+		for _, tai := range ran.SupportedTais() {
+			gnb.SupportedTais = append(gnb.SupportedTais, &gClient.Tai{
+				Plmn: &gClient.Plmn{Mcc: tai.Mcc, Mnc: tai.Mnc},
+				Tac:  tai.Tac,
+			})
+		}
+		h.Gnbs = append(h.Gnbs, gnb)
+		return true
+	})
+	return h
+}
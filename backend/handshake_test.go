@@ -0,0 +1,192 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	ctxt "context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/context"
+	gClient "github.com/omec-project/sctplb/sdcoreAmfServer"
+	"google.golang.org/grpc"
+)
+
+// fakeAmf is an NgapService whose streams are handled by handle
+type fakeAmf struct {
+	gClient.UnimplementedNgapServiceServer
+	handle func(stream gClient.NgapService_HandleMessageServer) error
+}
+
+func (f *fakeAmf) HandleMessage(stream gClient.NgapService_HandleMessageServer) error {
+	return f.handle(stream)
+}
+
+// startFakeAmf serves handle on a local port and returns a session stream of
+// a backend connected to it
+func startFakeAmf(t *testing.T, handle func(gClient.NgapService_HandleMessageServer) error) (
+	*GrpcServer, gClient.NgapService_HandleMessageClient,
+) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	gClient.RegisterNgapServiceServer(server, &fakeAmf{handle: handle})
+	go func() { _ = server.Serve(ln) }()
+	t.Cleanup(server.Stop)
+
+	b := newGrpcServer("127.0.0.1", 0, &config.Configuration{HandshakeTimeout: 200 * time.Millisecond})
+	b.conn, err = grpc.NewClient(ln.Addr().String(), b.dialOpts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = b.conn.Close() })
+	sessionCtx, cancel := ctxt.WithCancel(ctxt.Background())
+	t.Cleanup(cancel)
+	b.cancelSession = cancel
+	stream, err := gClient.NewNgapServiceClient(b.conn).HandleMessage(sessionCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, stream
+}
+
+func Test_Handshake(t *testing.T) {
+	ctx := context.Sctplb_Self()
+	local, remote := net.Pipe()
+	defer remote.Close()
+	ran := &context.Ran{Conn: local, GnbIp: "10.5.0.1:38412"}
+	ran.SetRanId("gnb-5")
+	ran.SetSupportedTais([]context.Tai{{Mcc: "208", Mnc: "93", Tac: "000001"}})
+	ctx.RanPool.Store(local, ran)
+	defer ctx.DeleteRan(local)
+
+	tests := []struct {
+		name         string
+		answer       *gClient.Handshake
+		version      uint32
+		capabilities uint64
+		messages     int
+	}{
+		{
+			name:         "batching backend",
+			answer:       &gClient.Handshake{Version: 2, Capabilities: 0xff},
+			version:      protocolVersion,
+			capabilities: capabilities,
+			messages:     1,
+		},
+		{
+			name: "backend without batching",
+			answer: &gClient.Handshake{
+				Version:      1,
+				Capabilities: uint64(gClient.Capability_CAP_DRAIN | gClient.Capability_CAP_LOAD_REPORT),
+			},
+			version:      1,
+			capabilities: uint64(gClient.Capability_CAP_DRAIN | gClient.Capability_CAP_LOAD_REPORT),
+			messages:     2,
+		},
+		{name: "legacy backend", answer: nil, version: 0, capabilities: 0, messages: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := make(chan *gClient.SctplbMessage, 8)
+			b, stream := startFakeAmf(t, func(s gClient.NgapService_HandleMessageServer) error {
+				for {
+					req, err := s.Recv()
+					if err != nil {
+						return nil
+					}
+					received <- req
+					answer := &gClient.AmfMessage{Msgtype: gClient.MsgType_INIT_MSG, AmfId: "amf-1"}
+					if req.Handshake != nil {
+						answer.Handshake = tt.answer
+					}
+					if err := s.Send(answer); err != nil {
+						return err
+					}
+				}
+			})
+			if err := b.handshake(stream); err != nil {
+				t.Fatalf("handshake failed: %v", err)
+			}
+			if b.version.Load() != tt.version || b.capabilities.Load() != tt.capabilities {
+				t.Errorf("negotiation mismatch. got = %d/%#x, want = %d/%#x",
+					b.version.Load(), b.capabilities.Load(), tt.version, tt.capabilities)
+			}
+			if len(received) != tt.messages {
+				t.Fatalf("handshake message count mismatch. got = %d, want = %d", len(received), tt.messages)
+			}
+			h := (<-received).Handshake
+			if h == nil || h.Version != protocolVersion || h.Capabilities != capabilities {
+				t.Fatalf("handshake message mismatch. got = %v", h)
+			}
+			var gnb *gClient.GnbInfo
+			for _, g := range h.Gnbs {
+				if g.GnbId == "gnb-5" {
+					gnb = g
+				}
+			}
+			if gnb == nil || len(gnb.Addresses) != 1 || gnb.Addresses[0] != "10.5.0.1:38412" ||
+				len(gnb.SupportedTais) != 1 || gnb.SupportedTais[0].Tac != "000001" {
+				t.Errorf("gNB inventory mismatch. got = %v", h.Gnbs)
+			}
+			if tt.messages > 1 {
+				if req := <-received; req.Msgtype != gClient.MsgType_INIT_MSG || req.GnbId != "gnb-5" {
+					t.Errorf("gNB announcement mismatch. got = %v", req)
+				}
+			}
+		})
+	}
+}
+
+func Test_HandshakeFailures(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		b, stream := startFakeAmf(t, func(s gClient.NgapService_HandleMessageServer) error {
+			<-s.Context().Done()
+			return nil
+		})
+		if err := b.handshake(stream); !errors.Is(err, errHandshakeTimeout) {
+			t.Errorf("handshake error mismatch. got = %v, want = %v", err, errHandshakeTimeout)
+		}
+	})
+	t.Run("unexpected answer", func(t *testing.T) {
+		b, stream := startFakeAmf(t, func(s gClient.NgapService_HandleMessageServer) error {
+			if _, err := s.Recv(); err != nil {
+				return err
+			}
+			return s.Send(&gClient.AmfMessage{Msgtype: gClient.MsgType_AMF_MSG})
+		})
+		if err := b.handshake(stream); err == nil {
+			t.Errorf("handshake with an unexpected answer expected error")
+		}
+	})
+	t.Run("unsupported version", func(t *testing.T) {
+		b, stream := startFakeAmf(t, func(s gClient.NgapService_HandleMessageServer) error {
+			if _, err := s.Recv(); err != nil {
+				return err
+			}
+			return s.Send(&gClient.AmfMessage{
+				Msgtype:   gClient.MsgType_INIT_MSG,
+				Handshake: &gClient.Handshake{Version: 0, Capabilities: capabilities},
+			})
+		})
+		if err := b.handshake(stream); err == nil {
+			t.Errorf("handshake with an unsupported version expected error")
+		}
+	})
+	t.Run("stream closed", func(t *testing.T) {
+		b, stream := startFakeAmf(t, func(s gClient.NgapService_HandleMessageServer) error {
+			return errors.New("rejected")
+		})
+		if err := b.handshake(stream); err == nil {
+			t.Errorf("handshake on a closed stream expected error")
+		}
+	})
+}
@@ -0,0 +1,236 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ishidawataru/sctp"
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/context"
+	"github.com/omec-project/sctplb/logger"
+)
+
+const defaultHoldMaxAge = 10 * time.Second
+
+// HoldStats are the counters of the hold queues
+type HoldStats struct {
+	Held     int    `json:"held"`
+	Replayed uint64 `json:"replayed"`
+	Expired  uint64 `json:"expired"`
+	Rejected uint64 `json:"rejected"`
+}
+
+type heldMessage struct {
+	msg  []byte
+	m    *ngapMessage
+	info *sctp.SndRcvInfo
+	at   time.Time
+}
+
+// heldQueue holds the uplink messages of one association in arrival order
+type heldQueue struct {
+	msgs []heldMessage
+	// replaying is set while a replay sends the messages, so a single
+	// replay works on the queue
+	replaying bool
+}
+
+// holdTable holds the uplink messages of the associations while no backend
+// is ready. Once an association has held messages its new messages are held
+// behind them, and a replay sends them in order when a backend becomes
+// ready. Messages held for longer than maxAge expire and are answered like
+// any message without a backend.
+type holdTable struct {
+	mu     sync.Mutex
+	depth  int
+	maxAge time.Duration
+	queues map[*context.Ran]*heldQueue
+	timer  *time.Timer
+	// route sends a message to a backend, it reports false when there is
+	// none
+	route    func(ran *context.Ran, msg []byte, m *ngapMessage, info *sctp.SndRcvInfo) bool
+	replayed atomic.Uint64
+	expired  atomic.Uint64
+	rejected atomic.Uint64
+}
+
+var holds = &holdTable{
+	queues: make(map[*context.Ran]*heldQueue),
+	route:  routeMessage,
+}
+
+// SetHold configures the hold queues, they are disabled unless cfg sets a
+// depth. It has to be called before the SCTP service is started.
+func SetHold(cfg *config.Hold) {
+	holds.mu.Lock()
+	defer holds.mu.Unlock()
+	holds.depth, holds.maxAge = 0, defaultHoldMaxAge
+	if cfg == nil || cfg.Depth <= 0 {
+		return
+	}
+	holds.depth = cfg.Depth
+	if cfg.MaxAge > 0 {
+		holds.maxAge = cfg.MaxAge
+	}
+	context.Sctplb_Self().SubscribeNFState(func(nf context.NF, t context.NFTransition) {
+		if t.To == context.NFReady {
+			go holds.replay()
+		}
+	})
+}
+
+// HoldStatus returns the counters of the hold queues
+func HoldStatus() HoldStats {
+	holds.mu.Lock()
+	held := 0
+	for _, q := range holds.queues {
+		held += len(q.msgs)
+	}
+	holds.mu.Unlock()
+	return HoldStats{
+		Held:     held,
+		Replayed: holds.replayed.Load(),
+		Expired:  holds.expired.Load(),
+		Rejected: holds.rejected.Load(),
+	}
+}
+
+// holding reports whether ran has messages waiting for a backend
+func (t *holdTable) holding(ran *context.Ran) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.queues[ran] != nil
+}
+
+// hold appends a message of ran to its queue, it reports false when the hold
+// queues are disabled. When the queue of ran is full its oldest message makes
+// room and is returned to be answered, so the gNB is answered in the order it
+// sent the messages.
+func (t *holdTable) hold(ran *context.Ran, msg []byte, m *ngapMessage, info *sctp.SndRcvInfo) (evicted *heldMessage, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.depth <= 0 {
+		return nil, false
+	}
+	q := t.queues[ran]
+	if q == nil {
+		q = &heldQueue{}
+		t.queues[ran] = q
+	}
+	if len(q.msgs) >= t.depth {
+		t.rejected.Add(1)
+		oldest := q.msgs[0]
+		q.msgs = q.msgs[1:]
+		evicted = &oldest
+	}
+	held := heldMessage{msg: bytes.Clone(msg), m: m, at: time.Now()}
+	if info != nil {
+		i := *info
+		held.info = &i
+	}
+	q.msgs = append(q.msgs, held)
+	if t.timer == nil {
+		t.timer = time.AfterFunc(t.maxAge, t.expire)
+	}
+	return evicted, true
+}
+
+// replay sends the held messages of every association to the backends
+func (t *holdTable) replay() {
+	t.mu.Lock()
+	var rans []*context.Ran
+	for ran, q := range t.queues {
+		if !q.replaying {
+			q.replaying = true
+			rans = append(rans, ran)
+		}
+	}
+	t.mu.Unlock()
+	for _, ran := range rans {
+		t.replayRan(ran)
+	}
+}
+
+// replayRan sends the held messages of ran in order, it stops at the first
+// message no backend takes and leaves it at the head of the queue
+func (t *holdTable) replayRan(ran *context.Ran) {
+	for {
+		t.mu.Lock()
+		q := t.queues[ran]
+		if q == nil {
+			t.mu.Unlock()
+			return
+		}
+		if len(q.msgs) == 0 {
+			delete(t.queues, ran)
+			t.mu.Unlock()
+			return
+		}
+		held := q.msgs[0]
+		q.msgs = q.msgs[1:]
+		t.mu.Unlock()
+
+		if !t.route(ran, held.msg, held.m, held.info) {
+			t.mu.Lock()
+			if q := t.queues[ran]; q != nil {
+				q.msgs = append([]heldMessage{held}, q.msgs...)
+				q.replaying = false
+			}
+			t.mu.Unlock()
+			return
+		}
+		t.replayed.Add(1)
+	}
+}
+
+// expire answers the messages held for longer than maxAge and waits for the
+// next one to expire
+func (t *holdTable) expire() {
+	type expiredMessage struct {
+		ran *context.Ran
+		m   *ngapMessage
+	}
+	var expired []expiredMessage
+	now := time.Now()
+	t.mu.Lock()
+	var next time.Time
+	for ran, q := range t.queues {
+		n := 0
+		for n < len(q.msgs) && now.Sub(q.msgs[n].at) >= t.maxAge {
+			expired = append(expired, expiredMessage{ran: ran, m: q.msgs[n].m})
+			n++
+		}
+		q.msgs = q.msgs[n:]
+		if len(q.msgs) == 0 && !q.replaying {
+			delete(t.queues, ran)
+		} else if len(q.msgs) > 0 && (next.IsZero() || q.msgs[0].at.Before(next)) {
+			next = q.msgs[0].at
+		}
+	}
+	t.timer = nil
+	if !next.IsZero() {
+		t.timer = time.AfterFunc(next.Add(t.maxAge).Sub(now), t.expire)
+	}
+	t.mu.Unlock()
+
+	for _, e := range expired {
+		t.expired.Add(1)
+		rejectNoBackend(e.ran, e.m)
+	}
+	if len(expired) > 0 {
+		logger.SctpLog.Warnf("%d held messages expired without a backend", len(expired))
+	}
+}
+
+// release drops the held messages of a disconnected gNB
+func (t *holdTable) release(ran *context.Ran) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.queues, ran)
+}
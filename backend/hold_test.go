@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ishidawataru/sctp"
+	"github.com/omec-project/ngap/v2/ngapType"
+	"github.com/omec-project/sctplb/context"
+	"github.com/omec-project/sctplb/logger"
+)
+
+func Test_HoldReplay(t *testing.T) {
+	available := false
+	var routed [][]byte
+	table := &holdTable{
+		depth:  3,
+		maxAge: time.Hour,
+		queues: map[*context.Ran]*heldQueue{},
+		route: func(ran *context.Ran, msg []byte, m *ngapMessage, info *sctp.SndRcvInfo) bool {
+			if !available {
+				return false
+			}
+			routed = append(routed, msg)
+			return true
+		},
+	}
+	defer func() {
+		table.mu.Lock()
+		if table.timer != nil {
+			table.timer.Stop()
+		}
+		table.mu.Unlock()
+	}()
+	ran := &context.Ran{}
+	if table.holding(ran) {
+		t.Fatal("gNB without held messages is holding")
+	}
+	buf := []byte{0}
+	for i := range 3 {
+		buf[0] = byte(i)
+		if evicted, ok := table.hold(ran, buf, nil, &sctp.SndRcvInfo{Stream: 1}); !ok || evicted != nil {
+			t.Fatalf("message %d was not held", i)
+		}
+	}
+	// the oldest message makes room for a message beyond the depth
+	buf[0] = 3
+	if evicted, ok := table.hold(ran, buf, nil, nil); !ok || evicted == nil || evicted.msg[0] != 0 {
+		t.Errorf("oldest message was not evicted. got = %v", evicted)
+	}
+	if !table.holding(ran) || table.rejected.Load() != 1 {
+		t.Fatalf("hold queue mismatch. holding = %v, rejected = %d", table.holding(ran), table.rejected.Load())
+	}
+
+	table.replay()
+	if len(routed) != 0 || len(table.queues[ran].msgs) != 3 || table.queues[ran].replaying {
+		t.Fatalf("replay without backend changed the queue")
+	}
+
+	available = true
+	table.replay()
+	if len(routed) != 3 || table.replayed.Load() != 3 {
+		t.Fatalf("replayed messages mismatch. got = %d", len(routed))
+	}
+	for i, msg := range routed {
+		if msg[0] != byte(i+1) {
+			t.Errorf("message %d replayed out of order. got = %d", i, msg[0])
+		}
+	}
+	if table.holding(ran) {
+		t.Errorf("gNB is still holding after the replay")
+	}
+
+	disabled := &holdTable{queues: map[*context.Ran]*heldQueue{}}
+	if _, ok := disabled.hold(ran, buf, nil, nil); ok {
+		t.Errorf("message held by disabled hold queues")
+	}
+}
+
+func Test_HoldExpire(t *testing.T) {
+	table := &holdTable{
+		depth:  2,
+		maxAge: 20 * time.Millisecond,
+		queues: map[*context.Ran]*heldQueue{},
+		route: func(ran *context.Ran, msg []byte, m *ngapMessage, info *sctp.SndRcvInfo) bool {
+			return false
+		},
+	}
+	local, remote := net.Pipe()
+	defer remote.Close()
+	ran := &context.Ran{Conn: local, Log: logger.RanLog}
+	received := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 1024)
+		n, err := remote.Read(buf)
+		if err == nil {
+			received <- buf[:n]
+		}
+	}()
+
+	setup := ngSetupRequest(t, []byte{0, 0, 1})
+	if _, ok := table.hold(ran, setup, mustDecodeNgap(t, setup), nil); !ok {
+		t.Fatal("NG Setup Request was not held")
+	}
+	select {
+	case msg := <-received:
+		m := mustDecodeNgap(t, msg)
+		if m.pdu.Present != ngapType.NGAPPDUPresentUnsuccessfulOutcome {
+			t.Errorf("expired NG Setup Request was not answered with a failure")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expired NG Setup Request was not answered")
+	}
+	if table.expired.Load() != 1 || table.holding(ran) {
+		t.Errorf("expiry mismatch. expired = %d, holding = %v", table.expired.Load(), table.holding(ran))
+	}
+}
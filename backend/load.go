@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/omec-project/sctplb/logger"
+	gClient "github.com/omec-project/sctplb/sdcoreAmfServer"
+)
+
+const (
+	// defaultLoadReportTTL is how long a load report takes to decay
+	defaultLoadReportTTL = 30 * time.Second
+	// neutralCapacity is the capacity of a backend without a load report, it
+	// is the one of an idle backend reporting a relative capacity of 100
+	neutralCapacity     = 100
+	maxRelativeCapacity = 255
+)
+
+// LoadStatus is the last load report of a backend
+type LoadStatus struct {
+	RelativeCapacity uint32    `json:"relativeCapacity"`
+	Load             uint32    `json:"load"`
+	ReportedAt       time.Time `json:"reportedAt"`
+	// Capacity is the free capacity the scheduler currently assumes
+	Capacity float64 `json:"capacity"`
+}
+
+// backendLoad keeps the load reported by a backend. The free capacity of a
+// report is its relative capacity scaled down by the load, as the report ages
+// it decays linearly to neutralCapacity, which it reaches after ttl.
+type backendLoad struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	capacity uint32
+	load     uint32
+	at       time.Time
+}
+
+func (l *backendLoad) report(r *gClient.LoadReport) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.capacity = min(r.RelativeCapacity, maxRelativeCapacity)
+	l.load = min(r.Load, 100)
+	l.at = time.Now()
+}
+
+// reset forgets the last report, the reports of a previous session do not
+// describe the backend anymore
+func (l *backendLoad) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.at = time.Time{}
+}
+
+// freeCapacity returns the decayed free capacity at now
+func (l *backendLoad) freeCapacity(now time.Time) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.freeCapacityLocked(now)
+}
+
+func (l *backendLoad) freeCapacityLocked(now time.Time) float64 {
+	if l.at.IsZero() {
+		return neutralCapacity
+	}
+	ttl := l.ttl
+	if ttl <= 0 {
+		ttl = defaultLoadReportTTL
+	}
+	age := now.Sub(l.at)
+	if age >= ttl {
+		return neutralCapacity
+	}
+	reported := float64(l.capacity) * float64(100-l.load) / 100
+	f := float64(age) / float64(ttl)
+	return reported*(1-f) + neutralCapacity*f
+}
+
+func (l *backendLoad) status(now time.Time) *LoadStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.at.IsZero() {
+		return nil
+	}
+	return &LoadStatus{
+		RelativeCapacity: l.capacity,
+		Load:             l.load,
+		ReportedAt:       l.at,
+		Capacity:         l.freeCapacityLocked(now),
+	}
+}
+
+// reportLoad records a LOAD_REPORT of the backend
+func (b *GrpcServer) reportLoad(r *gClient.LoadReport) {
+	if r == nil {
+		logger.GrpcLog.Warnf("empty load report from server %v", b.address)
+		return
+	}
+	b.load.report(r)
+	logger.GrpcLog.Debugf("server %v reported capacity %d load %d%%", b.address, r.RelativeCapacity, r.Load)
+}
+
+// LoadStatus returns the last load report of the backend, nil if it never
+// reported its load
+func (b *GrpcServer) LoadStatus() *LoadStatus {
+	return b.load.status(time.Now())
+}
@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"testing"
+	"time"
+
+	gClient "github.com/omec-project/sctplb/sdcoreAmfServer"
+)
+
+func Test_BackendLoadDecay(t *testing.T) {
+	l := backendLoad{ttl: 10 * time.Second}
+	now := time.Now()
+	if got := l.freeCapacity(now); got != neutralCapacity {
+		t.Errorf("capacity without report mismatch. got = %v, want = %v", got, neutralCapacity)
+	}
+	if l.status(now) != nil {
+		t.Errorf("status without report must be nil")
+	}
+
+	l.report(&gClient.LoadReport{RelativeCapacity: 300, Load: 40})
+	at := l.status(now).ReportedAt
+	tests := []struct {
+		name string
+		age  time.Duration
+		want float64
+	}{
+		// the relative capacity is clamped to 255
+		{name: "fresh", age: 0, want: 153},
+		{name: "half decayed", age: 5 * time.Second, want: 126.5},
+		{name: "stale", age: 10 * time.Second, want: neutralCapacity},
+	}
+	for _, tt := range tests {
+		if got := l.freeCapacity(at.Add(tt.age)); got != tt.want {
+			t.Errorf("%s: capacity mismatch. got = %v, want = %v", tt.name, got, tt.want)
+		}
+	}
+
+	l.reset()
+	if got := l.freeCapacity(at); got != neutralCapacity {
+		t.Errorf("capacity after reset mismatch. got = %v, want = %v", got, neutralCapacity)
+	}
+}
+
+func Test_ReportLoad(t *testing.T) {
+	b, stream := startFakeAmf(t, func(s gClient.NgapService_HandleMessageServer) error {
+		if err := s.Send(&gClient.AmfMessage{
+			Msgtype:    gClient.MsgType_LOAD_REPORT,
+			LoadReport: &gClient.LoadReport{RelativeCapacity: 10, Load: 20},
+		}); err != nil {
+			return err
+		}
+		<-s.Context().Done()
+		return nil
+	})
+	b.capabilities.Store(uint64(gClient.Capability_CAP_LOAD_REPORT))
+	go b.readFromServer(stream)
+	deadline := time.Now().Add(time.Second)
+	for b.LoadStatus() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("load report was not recorded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s := b.LoadStatus(); s.RelativeCapacity != 10 || s.Load != 20 {
+		t.Errorf("load status mismatch. got = %+v", s)
+	}
+}
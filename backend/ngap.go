@@ -0,0 +1,194 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"encoding/hex"
+	"fmt"
+	"reflect"
+
+	"github.com/omec-project/ngap/v2"
+	"github.com/omec-project/ngap/v2/ngapType"
+	"github.com/omec-project/sctplb/context"
+)
+
+// ngapMessage is the part of an NGAP PDU sctplb looks at to route it
+type ngapMessage struct {
+	pdu *ngapType.NGAPPDU
+	// ies is the list of protocol IEs of the message, it is nil for
+	// messages that do not carry any
+	ies reflect.Value
+	// amfUeNgapId is set for UE-associated messages the AMF already
+	// allocated an AMF UE NGAP ID for
+	amfUeNgapId    int64
+	hasAmfUeNgapId bool
+	// ranUeNgapId is set for UE-associated messages once the gNB allocated
+	// a RAN UE NGAP ID
+	ranUeNgapId    int64
+	hasRanUeNgapId bool
+}
+
+// decodeNgap decodes an NGAP PDU and extracts the UE NGAP IDs it carries
+func decodeNgap(b []byte) (*ngapMessage, error) {
+	pdu, err := ngap.Decoder(b)
+	if err != nil {
+		return nil, err
+	}
+	m := &ngapMessage{pdu: pdu}
+	var value reflect.Value
+	switch pdu.Present {
+	case ngapType.NGAPPDUPresentInitiatingMessage:
+		if pdu.InitiatingMessage == nil {
+			return nil, fmt.Errorf("initiating message is empty")
+		}
+		value = reflect.ValueOf(pdu.InitiatingMessage.Value)
+	case ngapType.NGAPPDUPresentSuccessfulOutcome:
+		if pdu.SuccessfulOutcome == nil {
+			return nil, fmt.Errorf("successful outcome is empty")
+		}
+		value = reflect.ValueOf(pdu.SuccessfulOutcome.Value)
+	case ngapType.NGAPPDUPresentUnsuccessfulOutcome:
+		if pdu.UnsuccessfulOutcome == nil {
+			return nil, fmt.Errorf("unsuccessful outcome is empty")
+		}
+		value = reflect.ValueOf(pdu.UnsuccessfulOutcome.Value)
+	default:
+		return nil, fmt.Errorf("unknown NGAP PDU type %d", pdu.Present)
+	}
+	m.ies = protocolIEs(value)
+	m.extractUeNgapIds()
+	return m, nil
+}
+
+// protocolIEs returns the IE list of the message held by the value of an
+// NGAP PDU. ngapType generates a distinct IE type for each of the many NGAP
+// messages, but all of them follow the same layout: the value holds one
+// non-nil message pointer whose ProtocolIEs.List is a slice of IEs.
+func protocolIEs(value reflect.Value) reflect.Value {
+	for i := range value.NumField() {
+		field := value.Field(i)
+		if field.Kind() != reflect.Pointer || field.IsNil() {
+			continue
+		}
+		message := field.Elem()
+		if message.Kind() != reflect.Struct {
+			return reflect.Value{}
+		}
+		ies := message.FieldByName("ProtocolIEs")
+		if !ies.IsValid() {
+			return reflect.Value{}
+		}
+		list := ies.FieldByName("List")
+		if list.Kind() != reflect.Slice {
+			return reflect.Value{}
+		}
+		return list
+	}
+	return reflect.Value{}
+}
+
+// extractUeNgapIds looks up the AMF and RAN UE NGAP IDs, either as IEs of
+// their own or as part of the UE NGAP IDs of a UE context release command
+func (m *ngapMessage) extractUeNgapIds() {
+	m.eachIE(func(value reflect.Value) bool {
+		if field := value.FieldByName("AMFUENGAPID"); field.IsValid() {
+			if id, _ := field.Interface().(*ngapType.AMFUENGAPID); id != nil {
+				m.amfUeNgapId, m.hasAmfUeNgapId = id.Value, true
+			}
+		}
+		if field := value.FieldByName("RANUENGAPID"); field.IsValid() {
+			if id, _ := field.Interface().(*ngapType.RANUENGAPID); id != nil {
+				m.ranUeNgapId, m.hasRanUeNgapId = id.Value, true
+			}
+		}
+		if field := value.FieldByName("UENGAPIDs"); field.IsValid() {
+			ids, _ := field.Interface().(*ngapType.UENGAPIDs)
+			switch {
+			case ids == nil:
+			case ids.UENGAPIDPair != nil:
+				m.amfUeNgapId, m.hasAmfUeNgapId = ids.UENGAPIDPair.AMFUENGAPID.Value, true
+				m.ranUeNgapId, m.hasRanUeNgapId = ids.UENGAPIDPair.RANUENGAPID.Value, true
+			case ids.AMFUENGAPID != nil:
+				m.amfUeNgapId, m.hasAmfUeNgapId = ids.AMFUENGAPID.Value, true
+			}
+		}
+		return true
+	})
+}
+
+// eachIE calls f with the value of every IE of the message until f returns false
+func (m *ngapMessage) eachIE(f func(value reflect.Value) bool) {
+	if !m.ies.IsValid() {
+		return
+	}
+	for i := range m.ies.Len() {
+		value := m.ies.Index(i).FieldByName("Value")
+		if value.Kind() != reflect.Struct {
+			continue
+		}
+		if !f(value) {
+			return
+		}
+	}
+}
+
+func (m *ngapMessage) initiating(present int) bool {
+	return m.pdu.Present == ngapType.NGAPPDUPresentInitiatingMessage &&
+		m.pdu.InitiatingMessage.Value.Present == present
+}
+
+// isInitialUEMessage reports whether the message starts a new UE association
+func (m *ngapMessage) isInitialUEMessage() bool {
+	return m.initiating(ngapType.InitiatingMessagePresentInitialUEMessage)
+}
+
+// isUEContextReleaseComplete reports whether the message ends a UE association
+func (m *ngapMessage) isUEContextReleaseComplete() bool {
+	return m.pdu.Present == ngapType.NGAPPDUPresentSuccessfulOutcome &&
+		m.pdu.SuccessfulOutcome.Value.Present == ngapType.SuccessfulOutcomePresentUEContextRelease
+}
+
+// supportedTais returns the tracking areas announced by an NG Setup Request or
+// a RAN Configuration Update, ok is false for messages that carry none
+func (m *ngapMessage) supportedTais() (tais []context.Tai, ok bool) {
+	m.eachIE(func(value reflect.Value) bool {
+		field := value.FieldByName("SupportedTAList")
+		if !field.IsValid() {
+			return true
+		}
+		list, _ := field.Interface().(*ngapType.SupportedTAList)
+		if list == nil {
+			return true
+		}
+		ok = true
+		for _, item := range list.List {
+			tac := hex.EncodeToString(item.TAC.Value)
+			for _, plmn := range item.BroadcastPLMNList.List {
+				mcc, mnc := plmnId(plmn.PLMNIdentity)
+				tais = append(tais, context.Tai{Mcc: mcc, Mnc: mnc, Tac: tac})
+			}
+		}
+		return false
+	})
+	return tais, ok
+}
+
+// plmnId decodes the BCD encoded MCC and MNC of a PLMN identity, the filler
+// digit 0xf marks a two digit MNC
+func plmnId(plmn ngapType.PLMNIdentity) (mcc, mnc string) {
+	b := plmn.Value
+	if len(b) != 3 {
+		return "", ""
+	}
+	digit := func(d byte) string {
+		return string(rune('0' + d))
+	}
+	mcc = digit(b[0]&0x0f) + digit(b[0]>>4) + digit(b[1]&0x0f)
+	mnc = digit(b[2]&0x0f) + digit(b[2]>>4)
+	if b[1]>>4 != 0x0f {
+		mnc += digit(b[1] >> 4)
+	}
+	return mcc, mnc
+}
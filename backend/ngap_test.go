@@ -0,0 +1,335 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"testing"
+
+	"github.com/omec-project/ngap/v2"
+	"github.com/omec-project/ngap/v2/ngapType"
+	"github.com/omec-project/sctplb/context"
+)
+
+func encodeNgap(t *testing.T, pdu ngapType.NGAPPDU) []byte {
+	t.Helper()
+	b, err := ngap.Encoder(pdu)
+	if err != nil {
+		t.Fatalf("encode NGAP PDU: %v", err)
+	}
+	return b
+}
+
+func mustDecodeNgap(t *testing.T, b []byte) *ngapMessage {
+	t.Helper()
+	m, err := decodeNgap(b)
+	if err != nil {
+		t.Fatalf("decode NGAP PDU: %v", err)
+	}
+	return m
+}
+
+func uplinkNASTransport(t *testing.T, amfUeNgapId, ranUeNgapId int64) []byte {
+	msg := ngapType.UplinkNASTransport{}
+	msg.ProtocolIEs.List = []ngapType.UplinkNASTransportIEs{
+		{
+			Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDAMFUENGAPID},
+			Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+			Value: ngapType.UplinkNASTransportIEsValue{
+				Present:     ngapType.UplinkNASTransportIEsPresentAMFUENGAPID,
+				AMFUENGAPID: &ngapType.AMFUENGAPID{Value: amfUeNgapId},
+			},
+		},
+		{
+			Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDRANUENGAPID},
+			Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+			Value: ngapType.UplinkNASTransportIEsValue{
+				Present:     ngapType.UplinkNASTransportIEsPresentRANUENGAPID,
+				RANUENGAPID: &ngapType.RANUENGAPID{Value: ranUeNgapId},
+			},
+		},
+	}
+	return encodeNgap(t, ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentInitiatingMessage,
+		InitiatingMessage: &ngapType.InitiatingMessage{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeUplinkNASTransport},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+			Value: ngapType.InitiatingMessageValue{
+				Present:            ngapType.InitiatingMessagePresentUplinkNASTransport,
+				UplinkNASTransport: &msg,
+			},
+		},
+	})
+}
+
+func ueContextReleaseCommand(t *testing.T, amfUeNgapId, ranUeNgapId int64) []byte {
+	msg := ngapType.UEContextReleaseCommand{}
+	msg.ProtocolIEs.List = []ngapType.UEContextReleaseCommandIEs{
+		{
+			Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDUENGAPIDs},
+			Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+			Value: ngapType.UEContextReleaseCommandIEsValue{
+				Present: ngapType.UEContextReleaseCommandIEsPresentUENGAPIDs,
+				UENGAPIDs: &ngapType.UENGAPIDs{
+					Present: ngapType.UENGAPIDsPresentUENGAPIDPair,
+					UENGAPIDPair: &ngapType.UENGAPIDPair{
+						AMFUENGAPID: ngapType.AMFUENGAPID{Value: amfUeNgapId},
+						RANUENGAPID: ngapType.RANUENGAPID{Value: ranUeNgapId},
+					},
+				},
+			},
+		},
+		{
+			Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDCause},
+			Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+			Value: ngapType.UEContextReleaseCommandIEsValue{
+				Present: ngapType.UEContextReleaseCommandIEsPresentCause,
+				Cause: &ngapType.Cause{
+					Present: ngapType.CausePresentNas,
+					Nas:     &ngapType.CauseNas{Value: ngapType.CauseNasPresentNormalRelease},
+				},
+			},
+		},
+	}
+	return encodeNgap(t, ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentInitiatingMessage,
+		InitiatingMessage: &ngapType.InitiatingMessage{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeUEContextRelease},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+			Value: ngapType.InitiatingMessageValue{
+				Present:          ngapType.InitiatingMessagePresentUEContextRelease,
+				UEContextRelease: &msg,
+			},
+		},
+	})
+}
+
+func ueContextReleaseComplete(t *testing.T, amfUeNgapId, ranUeNgapId int64) []byte {
+	msg := ngapType.UEContextReleaseComplete{}
+	msg.ProtocolIEs.List = []ngapType.UEContextReleaseCompleteIEs{
+		{
+			Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDAMFUENGAPID},
+			Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+			Value: ngapType.UEContextReleaseCompleteIEsValue{
+				Present:     ngapType.UEContextReleaseCompleteIEsPresentAMFUENGAPID,
+				AMFUENGAPID: &ngapType.AMFUENGAPID{Value: amfUeNgapId},
+			},
+		},
+		{
+			Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDRANUENGAPID},
+			Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+			Value: ngapType.UEContextReleaseCompleteIEsValue{
+				Present:     ngapType.UEContextReleaseCompleteIEsPresentRANUENGAPID,
+				RANUENGAPID: &ngapType.RANUENGAPID{Value: ranUeNgapId},
+			},
+		},
+	}
+	return encodeNgap(t, ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentSuccessfulOutcome,
+		SuccessfulOutcome: &ngapType.SuccessfulOutcome{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeUEContextRelease},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+			Value: ngapType.SuccessfulOutcomeValue{
+				Present:          ngapType.SuccessfulOutcomePresentUEContextRelease,
+				UEContextRelease: &msg,
+			},
+		},
+	})
+}
+
+func initialUEMessage(t *testing.T, ranUeNgapId int64) []byte {
+	return initialUEMessageWithCause(t, ranUeNgapId,
+		ngapType.RRCEstablishmentCause{Value: ngapType.RRCEstablishmentCausePresentMoSignalling})
+}
+
+func initialUEMessageWithCause(t *testing.T, ranUeNgapId int64, cause ngapType.RRCEstablishmentCause) []byte {
+	msg := ngapType.InitialUEMessage{}
+	msg.ProtocolIEs.List = []ngapType.InitialUEMessageIEs{
+		{
+			Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDRANUENGAPID},
+			Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+			Value: ngapType.InitialUEMessageIEsValue{
+				Present:     ngapType.InitialUEMessageIEsPresentRANUENGAPID,
+				RANUENGAPID: &ngapType.RANUENGAPID{Value: ranUeNgapId},
+			},
+		},
+		{
+			Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDRRCEstablishmentCause},
+			Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+			Value: ngapType.InitialUEMessageIEsValue{
+				Present:               ngapType.InitialUEMessageIEsPresentRRCEstablishmentCause,
+				RRCEstablishmentCause: &cause,
+			},
+		},
+	}
+	return encodeNgap(t, ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentInitiatingMessage,
+		InitiatingMessage: &ngapType.InitiatingMessage{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeInitialUEMessage},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+			Value: ngapType.InitiatingMessageValue{
+				Present:          ngapType.InitiatingMessagePresentInitialUEMessage,
+				InitialUEMessage: &msg,
+			},
+		},
+	})
+}
+
+func Test_DecodeNgap(t *testing.T) {
+	tests := []struct {
+		name           string
+		msg            []byte
+		amfUeNgapId    int64
+		hasAmfUeNgapId bool
+		initialUe      bool
+		releaseDone    bool
+	}{
+		{name: "uplink NAS transport", msg: uplinkNASTransport(t, 7, 1), amfUeNgapId: 7, hasAmfUeNgapId: true},
+		{name: "UE context release command", msg: ueContextReleaseCommand(t, 8, 2), amfUeNgapId: 8, hasAmfUeNgapId: true},
+		{name: "UE context release complete", msg: ueContextReleaseComplete(t, 9, 3), amfUeNgapId: 9, hasAmfUeNgapId: true, releaseDone: true},
+		{name: "initial UE message", msg: initialUEMessage(t, 4), initialUe: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := decodeNgap(tt.msg)
+			if err != nil {
+				t.Fatalf("decodeNgap failed: %v", err)
+			}
+			if m.hasAmfUeNgapId != tt.hasAmfUeNgapId || m.amfUeNgapId != tt.amfUeNgapId {
+				t.Errorf("AMF UE NGAP ID mismatch. got = %d (%v), want = %d (%v)",
+					m.amfUeNgapId, m.hasAmfUeNgapId, tt.amfUeNgapId, tt.hasAmfUeNgapId)
+			}
+			if m.isInitialUEMessage() != tt.initialUe {
+				t.Errorf("isInitialUEMessage mismatch. got = %v", m.isInitialUEMessage())
+			}
+			if m.isUEContextReleaseComplete() != tt.releaseDone {
+				t.Errorf("isUEContextReleaseComplete mismatch. got = %v", m.isUEContextReleaseComplete())
+			}
+		})
+	}
+	if _, err := decodeNgap([]byte{0xff}); err == nil {
+		t.Errorf("decodeNgap of garbage expected error")
+	}
+}
+
+func Test_UeBackend(t *testing.T) {
+	owner := readyGrpcServer("10.2.0.1")
+	ran := &context.Ran{}
+
+	owner.learnUeOwner(ran, mustDecodeNgap(t, ueContextReleaseCommand(t, 100, 1)))
+	defer ueOwners.releaseRan(ran)
+
+	m, _ := decodeNgap(uplinkNASTransport(t, 100, 1))
+	if got, owned := ueBackend(ran, m); got != owner || !owned {
+		t.Errorf("owner of UE 100 mismatch. got = %v, want = %v", got, owner)
+	}
+	m, _ = decodeNgap(uplinkNASTransport(t, 100, 2))
+	if got, owned := ueBackend(ran, m); got != nil || owned {
+		t.Errorf("unknown UE must be left to the scheduler. got = %v", got)
+	}
+	m, _ = decodeNgap(initialUEMessage(t, 2))
+	if got, owned := ueBackend(ran, m); got != nil || owned {
+		t.Errorf("initial UE message must be left to the scheduler. got = %v", got)
+	}
+
+	// the UE stays with a backend that reconnects
+	owner.setState(context.NFFailed, "test")
+	m, _ = decodeNgap(uplinkNASTransport(t, 100, 1))
+	if got, owned := ueBackend(ran, m); got != nil || !owned {
+		t.Errorf("UE of a failed backend must not be left to the scheduler. got = %v/%v", got, owned)
+	}
+
+	ueOwners.releaseBackend(owner)
+	if ueOwners.owner(ran, 1) != nil || ueOwners.size() != 0 {
+		t.Errorf("UEs of a removed backend were not released")
+	}
+}
+
+func Test_UeBackendSameAmfUeNgapId(t *testing.T) {
+	first, second := readyGrpcServer("10.2.0.2"), readyGrpcServer("10.2.0.3")
+	ran, other := &context.Ran{}, &context.Ran{}
+	defer ueOwners.releaseRan(ran)
+	defer ueOwners.releaseRan(other)
+
+	// both AMFs allocate AMF UE NGAP ID 100, to UEs of the same gNB and of
+	// another gNB
+	first.learnUeOwner(ran, mustDecodeNgap(t, ueContextReleaseCommand(t, 100, 1)))
+	second.learnUeOwner(ran, mustDecodeNgap(t, ueContextReleaseCommand(t, 100, 2)))
+	second.learnUeOwner(other, mustDecodeNgap(t, ueContextReleaseCommand(t, 100, 1)))
+
+	tests := []struct {
+		ran         *context.Ran
+		ranUeNgapId int64
+		want        Backend
+	}{
+		{ran: ran, ranUeNgapId: 1, want: first},
+		{ran: ran, ranUeNgapId: 2, want: second},
+		{ran: other, ranUeNgapId: 1, want: second},
+	}
+	for _, tt := range tests {
+		m := mustDecodeNgap(t, uplinkNASTransport(t, 100, tt.ranUeNgapId))
+		if got, _ := ueBackend(tt.ran, m); got != tt.want {
+			t.Errorf("owner of RAN UE %d mismatch. got = %v, want = %v", tt.ranUeNgapId, got, tt.want)
+		}
+	}
+
+	// releasing one UE leaves the other UEs with the same AMF UE NGAP ID
+	ueOwners.release(ran, 2)
+	if ueOwners.owner(ran, 1) != first || ueOwners.owner(other, 1) != second {
+		t.Errorf("release of one UE released another")
+	}
+}
+
+// ngSetupRequest encodes an NG Setup Request announcing tac in PLMN 208/93
+func ngSetupRequest(t *testing.T, tac []byte) []byte {
+	msg := ngapType.NGSetupRequest{}
+	msg.ProtocolIEs.List = []ngapType.NGSetupRequestIEs{
+		{
+			Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDSupportedTAList},
+			Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+			Value: ngapType.NGSetupRequestIEsValue{
+				Present: ngapType.NGSetupRequestIEsPresentSupportedTAList,
+				SupportedTAList: &ngapType.SupportedTAList{List: []ngapType.SupportedTAItem{{
+					TAC: ngapType.TAC{Value: tac},
+					BroadcastPLMNList: ngapType.BroadcastPLMNList{List: []ngapType.BroadcastPLMNItem{{
+						PLMNIdentity: ngapType.PLMNIdentity{Value: []byte{0x02, 0xf8, 0x39}},
+						TAISliceSupportList: ngapType.SliceSupportList{List: []ngapType.SliceSupportItem{{
+							SNSSAI: ngapType.SNSSAI{SST: ngapType.SST{Value: []byte{0x01}}},
+						}}},
+					}}},
+				}}},
+			},
+		},
+	}
+	return encodeNgap(t, ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentInitiatingMessage,
+		InitiatingMessage: &ngapType.InitiatingMessage{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeNGSetup},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+			Value: ngapType.InitiatingMessageValue{
+				Present: ngapType.InitiatingMessagePresentNGSetup,
+				NGSetup: &msg,
+			},
+		},
+	})
+}
+
+func Test_SupportedTais(t *testing.T) {
+	m, err := decodeNgap(ngSetupRequest(t, []byte{0x00, 0x00, 0x01}))
+	if err != nil {
+		t.Fatalf("decodeNgap failed: %v", err)
+	}
+	tais, ok := m.supportedTais()
+	want := context.Tai{Mcc: "208", Mnc: "93", Tac: "000001"}
+	if !ok || len(tais) != 1 || tais[0] != want {
+		t.Errorf("supported TAIs mismatch. got = %v, want = %v", tais, want)
+	}
+	if mcc, mnc := plmnId(ngapType.PLMNIdentity{Value: []byte{0x13, 0x00, 0x62}}); mcc != "310" || mnc != "260" {
+		t.Errorf("three digit MNC mismatch. got = %s/%s, want = 310/260", mcc, mnc)
+	}
+	m, _ = decodeNgap(uplinkNASTransport(t, 1, 1))
+	if _, ok := m.supportedTais(); ok {
+		t.Errorf("uplink NAS transport carries no TAIs")
+	}
+}
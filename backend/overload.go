@@ -0,0 +1,262 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/omec-project/ngap/v2/ngapType"
+	"github.com/omec-project/sctplb/context"
+	"github.com/omec-project/sctplb/logger"
+)
+
+// OverloadStatus is the NGAP overload control an AMF asked for
+type OverloadStatus struct {
+	Action string `json:"action"`
+	// TrafficLoadReduction is the percentage of the signalling subject to
+	// the action that is rejected, 0 when all of it is
+	TrafficLoadReduction int64     `json:"trafficLoadReduction,omitempty"`
+	Since                time.Time `json:"since"`
+}
+
+// overloadControl is the overload state of an AMF, set by an NGAP Overload
+// Start and cleared by an Overload Stop. The gNBs only see sctplb as a single
+// AMF, so sctplb enforces the overload of each AMF itself: the new UEs that
+// the overload action applies to are steered to other AMFs or rejected.
+type overloadControl struct {
+	mu        sync.Mutex
+	active    bool
+	action    ngapType.OverloadAction
+	reduction int64
+	since     time.Time
+	// credit accumulates the reduction percentage of every UE subject to
+	// the action, a UE is rejected each time it reaches 100
+	credit int64
+}
+
+func (o *overloadControl) start(action ngapType.OverloadAction, reduction int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.active {
+		o.since = time.Now()
+	}
+	o.active, o.action, o.reduction, o.credit = true, action, reduction, 0
+}
+
+func (o *overloadControl) stop() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.active = false
+}
+
+func (o *overloadControl) overloaded() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.active
+}
+
+// admits reports whether a new UE establishing its RRC connection with cause
+// may be sent to the AMF
+func (o *overloadControl) admits(cause ngapType.RRCEstablishmentCause) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.active || !overloadActionApplies(o.action, cause) {
+		return true
+	}
+	if o.reduction <= 0 {
+		return false
+	}
+	o.credit += o.reduction
+	if o.credit >= 100 {
+		o.credit -= 100
+		return false
+	}
+	return true
+}
+
+func (o *overloadControl) status() *OverloadStatus {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.active {
+		return nil
+	}
+	return &OverloadStatus{
+		Action:               overloadActionName(o.action),
+		TrafficLoadReduction: o.reduction,
+		Since:                o.since,
+	}
+}
+
+// overloadActionApplies reports whether action rejects the RRC connection
+// establishments with cause, as listed in TS 38.413 section 8.7.6.2
+func overloadActionApplies(action ngapType.OverloadAction, cause ngapType.RRCEstablishmentCause) bool {
+	switch action.Value {
+	case ngapType.OverloadActionPresentRejectNonEmergencyMoDt:
+		switch cause.Value {
+		case ngapType.RRCEstablishmentCausePresentMoData,
+			ngapType.RRCEstablishmentCausePresentMoVoiceCall,
+			ngapType.RRCEstablishmentCausePresentMoVideoCall,
+			ngapType.RRCEstablishmentCausePresentMoSMS:
+			return true
+		}
+		return false
+	case ngapType.OverloadActionPresentRejectRrcCrSignalling:
+		switch cause.Value {
+		case ngapType.RRCEstablishmentCausePresentMoData,
+			ngapType.RRCEstablishmentCausePresentMoVoiceCall,
+			ngapType.RRCEstablishmentCausePresentMoVideoCall,
+			ngapType.RRCEstablishmentCausePresentMoSMS,
+			ngapType.RRCEstablishmentCausePresentMoSignalling:
+			return true
+		}
+		return false
+	case ngapType.OverloadActionPresentPermitEmergencySessionsAndMobileTerminatedServicesOnly:
+		return cause.Value != ngapType.RRCEstablishmentCausePresentEmergency &&
+			cause.Value != ngapType.RRCEstablishmentCausePresentMtAccess
+	case ngapType.OverloadActionPresentPermitHighPrioritySessionsAndMobileTerminatedServicesOnly:
+		switch cause.Value {
+		case ngapType.RRCEstablishmentCausePresentHighPriorityAccess,
+			ngapType.RRCEstablishmentCausePresentMpsPriorityAccess,
+			ngapType.RRCEstablishmentCausePresentMcsPriorityAccess,
+			ngapType.RRCEstablishmentCausePresentMtAccess:
+			return false
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func overloadActionName(action ngapType.OverloadAction) string {
+	switch action.Value {
+	case ngapType.OverloadActionPresentRejectNonEmergencyMoDt:
+		return "reject-non-emergency-mo-dt"
+	case ngapType.OverloadActionPresentRejectRrcCrSignalling:
+		return "reject-rrc-cr-signalling"
+	case ngapType.OverloadActionPresentPermitEmergencySessionsAndMobileTerminatedServicesOnly:
+		return "permit-emergency-sessions-and-mobile-terminated-services-only"
+	case ngapType.OverloadActionPresentPermitHighPrioritySessionsAndMobileTerminatedServicesOnly:
+		return "permit-high-priority-sessions-and-mobile-terminated-services-only"
+	default:
+		return "unknown"
+	}
+}
+
+// isOverloadStart reports whether the message is an NGAP Overload Start
+func (m *ngapMessage) isOverloadStart() bool {
+	return m.initiating(ngapType.InitiatingMessagePresentOverloadStart)
+}
+
+// isOverloadStop reports whether the message is an NGAP Overload Stop
+func (m *ngapMessage) isOverloadStop() bool {
+	return m.initiating(ngapType.InitiatingMessagePresentOverloadStop)
+}
+
+// overloadStart returns the overload action and traffic load reduction of an
+// Overload Start. ok is false when it only carries the overload of some
+// slices, which is not enforced. Without an AMF Overload Response the AMF
+// asks for the mildest action.
+func (m *ngapMessage) overloadStart() (action ngapType.OverloadAction, reduction int64, ok bool) {
+	action.Value = ngapType.OverloadActionPresentRejectNonEmergencyMoDt
+	var hasResponse, hasSlices bool
+	for _, ie := range m.pdu.InitiatingMessage.Value.OverloadStart.ProtocolIEs.List {
+		switch ie.Value.Present {
+		case ngapType.OverloadStartIEsPresentAMFOverloadResponse:
+			hasResponse = true
+			if r := ie.Value.AMFOverloadResponse; r != nil && r.OverloadAction != nil {
+				action = *r.OverloadAction
+			}
+		case ngapType.OverloadStartIEsPresentAMFTrafficLoadReductionIndication:
+			if r := ie.Value.AMFTrafficLoadReductionIndication; r != nil {
+				reduction = r.Value
+			}
+		case ngapType.OverloadStartIEsPresentOverloadStartNSSAIList:
+			hasSlices = true
+		}
+	}
+	return action, reduction, hasResponse || !hasSlices
+}
+
+// rrcEstablishmentCause returns the RRC establishment cause of an
+// InitialUEMessage, not-available when it carries none
+func (m *ngapMessage) rrcEstablishmentCause() ngapType.RRCEstablishmentCause {
+	cause := ngapType.RRCEstablishmentCause{Value: ngapType.RRCEstablishmentCausePresentNotAvailable}
+	for _, ie := range m.pdu.InitiatingMessage.Value.InitialUEMessage.ProtocolIEs.List {
+		if ie.Value.Present == ngapType.InitialUEMessageIEsPresentRRCEstablishmentCause &&
+			ie.Value.RRCEstablishmentCause != nil {
+			cause = *ie.Value.RRCEstablishmentCause
+		}
+	}
+	return cause
+}
+
+// handleOverload applies an Overload Start or Stop of the backend, it reports
+// whether m was one. They are not forwarded: the gNB would throttle every AMF
+// behind sctplb for the overload of this one.
+func (b *GrpcServer) handleOverload(m *ngapMessage) bool {
+	switch {
+	case m == nil:
+		return false
+	case m.isOverloadStart():
+		action, reduction, ok := m.overloadStart()
+		if !ok {
+			logger.GrpcLog.Infof("server %v reported the overload of some slices only, not enforced", b.address)
+			return true
+		}
+		b.overload.start(action, reduction)
+		logger.GrpcLog.Warnf("server %v is overloaded: %s, traffic load reduction %d%%",
+			b.address, overloadActionName(action), reduction)
+		return true
+	case m.isOverloadStop():
+		b.overload.stop()
+		logger.GrpcLog.Infof("server %v is no longer overloaded", b.address)
+		return true
+	default:
+		return false
+	}
+}
+
+// Overloaded reports whether the backend asked for overload control
+func (b *GrpcServer) Overloaded() bool {
+	return b.overload.overloaded()
+}
+
+// OverloadStatus returns the overload control of the backend, nil when it is
+// not overloaded
+func (b *GrpcServer) OverloadStatus() *OverloadStatus {
+	return b.overload.status()
+}
+
+// overloadControlled is implemented by backends that can be overloaded
+type overloadControlled interface {
+	Overloaded() bool
+	admits(cause ngapType.RRCEstablishmentCause) bool
+}
+
+func (b *GrpcServer) admits(cause ngapType.RRCEstablishmentCause) bool {
+	return b.overload.admits(cause)
+}
+
+// admitInitialUE returns the backend a new UE is sent to once the overload
+// control of backend, the scheduler's choice, was applied: a UE the overload
+// action of backend rejects is steered to an eligible backend that is not
+// overloaded, nil when there is none and the UE is to be rejected
+func admitInitialUE(backend Backend, ran *context.Ran, m *ngapMessage) Backend {
+	o, ok := backend.(overloadControlled)
+	if !ok || o.admits(m.rrcEstablishmentCause()) {
+		return backend
+	}
+	var others []context.NF
+	for _, nf := range eligibleBackends() {
+		if o, ok := nf.(overloadControlled); !ok || !o.Overloaded() {
+			others = append(others, nf)
+		}
+	}
+	if len(others) == 0 {
+		return nil
+	}
+	return scheduler.Select(others, ran)
+}
@@ -0,0 +1,250 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/omec-project/ngap/v2/ngapType"
+	"github.com/omec-project/sctplb/context"
+	"github.com/omec-project/sctplb/logger"
+)
+
+// overloadStartMsg encodes an Overload Start asking for action, with a
+// traffic load reduction unless reduction is 0
+func overloadStartMsg(t *testing.T, action ngapType.OverloadAction, reduction int64) []byte {
+	msg := ngapType.OverloadStart{}
+	msg.ProtocolIEs.List = []ngapType.OverloadStartIEs{
+		{
+			Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDAMFOverloadResponse},
+			Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+			Value: ngapType.OverloadStartIEsValue{
+				Present: ngapType.OverloadStartIEsPresentAMFOverloadResponse,
+				AMFOverloadResponse: &ngapType.OverloadResponse{
+					Present:        ngapType.OverloadResponsePresentOverloadAction,
+					OverloadAction: &action,
+				},
+			},
+		},
+	}
+	if reduction > 0 {
+		msg.ProtocolIEs.List = append(msg.ProtocolIEs.List, ngapType.OverloadStartIEs{
+			Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDAMFTrafficLoadReductionIndication},
+			Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+			Value: ngapType.OverloadStartIEsValue{
+				Present:                           ngapType.OverloadStartIEsPresentAMFTrafficLoadReductionIndication,
+				AMFTrafficLoadReductionIndication: &ngapType.TrafficLoadReductionIndication{Value: reduction},
+			},
+		})
+	}
+	return encodeNgap(t, ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentInitiatingMessage,
+		InitiatingMessage: &ngapType.InitiatingMessage{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeOverloadStart},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+			Value: ngapType.InitiatingMessageValue{
+				Present:       ngapType.InitiatingMessagePresentOverloadStart,
+				OverloadStart: &msg,
+			},
+		},
+	})
+}
+
+func overloadStopMsg(t *testing.T) []byte {
+	return encodeNgap(t, ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentInitiatingMessage,
+		InitiatingMessage: &ngapType.InitiatingMessage{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeOverloadStop},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+			Value: ngapType.InitiatingMessageValue{
+				Present:      ngapType.InitiatingMessagePresentOverloadStop,
+				OverloadStop: &ngapType.OverloadStop{},
+			},
+		},
+	})
+}
+
+func Test_OverloadActionApplies(t *testing.T) {
+	tests := []struct {
+		name   string
+		action ngapType.OverloadAction
+		cause  ngapType.RRCEstablishmentCause
+		want   bool
+	}{
+		{
+			name:   "mo data rejected",
+			action: ngapType.OverloadAction{Value: ngapType.OverloadActionPresentRejectNonEmergencyMoDt},
+			cause:  ngapType.RRCEstablishmentCause{Value: ngapType.RRCEstablishmentCausePresentMoData},
+			want:   true,
+		},
+		{
+			name:   "mo signalling kept by mo data rejection",
+			action: ngapType.OverloadAction{Value: ngapType.OverloadActionPresentRejectNonEmergencyMoDt},
+			cause:  ngapType.RRCEstablishmentCause{Value: ngapType.RRCEstablishmentCausePresentMoSignalling},
+			want:   false,
+		},
+		{
+			name:   "mo signalling rejected",
+			action: ngapType.OverloadAction{Value: ngapType.OverloadActionPresentRejectRrcCrSignalling},
+			cause:  ngapType.RRCEstablishmentCause{Value: ngapType.RRCEstablishmentCausePresentMoSignalling},
+			want:   true,
+		},
+		{
+			name: "emergency permitted",
+			action: ngapType.OverloadAction{
+				Value: ngapType.OverloadActionPresentPermitEmergencySessionsAndMobileTerminatedServicesOnly,
+			},
+			cause: ngapType.RRCEstablishmentCause{Value: ngapType.RRCEstablishmentCausePresentEmergency},
+			want:  false,
+		},
+		{
+			name: "high priority rejected when only emergency is permitted",
+			action: ngapType.OverloadAction{
+				Value: ngapType.OverloadActionPresentPermitEmergencySessionsAndMobileTerminatedServicesOnly,
+			},
+			cause: ngapType.RRCEstablishmentCause{Value: ngapType.RRCEstablishmentCausePresentHighPriorityAccess},
+			want:  true,
+		},
+		{
+			name: "high priority permitted",
+			action: ngapType.OverloadAction{
+				Value: ngapType.OverloadActionPresentPermitHighPrioritySessionsAndMobileTerminatedServicesOnly,
+			},
+			cause: ngapType.RRCEstablishmentCause{Value: ngapType.RRCEstablishmentCausePresentHighPriorityAccess},
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		if got := overloadActionApplies(tt.action, tt.cause); got != tt.want {
+			t.Errorf("%s: overloadActionApplies = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_HandleOverload(t *testing.T) {
+	b := readyGrpcServer("10.6.0.1")
+	moData := ngapType.RRCEstablishmentCause{Value: ngapType.RRCEstablishmentCausePresentMoData}
+	rejectMoData := ngapType.OverloadAction{Value: ngapType.OverloadActionPresentRejectNonEmergencyMoDt}
+
+	if b.handleOverload(mustDecodeNgap(t, uplinkNASTransport(t, 1, 1))) {
+		t.Errorf("uplink NAS transport handled as overload control")
+	}
+	if !b.handleOverload(mustDecodeNgap(t, overloadStartMsg(t, rejectMoData, 25))) {
+		t.Fatal("overload start was not handled")
+	}
+	if !b.Overloaded() {
+		t.Fatal("backend is not overloaded")
+	}
+	if s := b.OverloadStatus(); s == nil || s.Action != "reject-non-emergency-mo-dt" || s.TrafficLoadReduction != 25 {
+		t.Errorf("overload status mismatch. got = %+v", s)
+	}
+	rejected := 0
+	for range 20 {
+		if !b.admits(moData) {
+			rejected++
+		}
+	}
+	if rejected != 5 {
+		t.Errorf("rejected UEs mismatch. got = %d, want = 5", rejected)
+	}
+
+	if !b.handleOverload(mustDecodeNgap(t, overloadStopMsg(t))) {
+		t.Fatal("overload stop was not handled")
+	}
+	if b.Overloaded() || b.OverloadStatus() != nil || !b.admits(moData) {
+		t.Errorf("backend is still overloaded")
+	}
+}
+
+func Test_AdmitInitialUE(t *testing.T) {
+	// steering must not move the shared round-robin of the other tests
+	defer func(s Scheduler) { scheduler = s }(scheduler)
+	scheduler = &roundRobinScheduler{}
+	ctx := context.Sctplb_Self()
+	overloaded, other := readyGrpcServer("10.6.0.2"), readyGrpcServer("10.6.0.3")
+	unhealthy := readyGrpcServer("10.6.0.4")
+	unhealthy.unhealthyUntil.Store(time.Now().Add(time.Hour).UnixNano())
+	ctx.AddNF(overloaded)
+	ctx.AddNF(other)
+	ctx.AddNF(unhealthy)
+	defer ctx.DeleteNF(overloaded)
+	defer ctx.DeleteNF(other)
+	defer ctx.DeleteNF(unhealthy)
+	overloaded.overload.start(ngapType.OverloadAction{Value: ngapType.OverloadActionPresentRejectNonEmergencyMoDt}, 0)
+
+	moData := mustDecodeNgap(t, initialUEMessageWithCause(t, 1,
+		ngapType.RRCEstablishmentCause{Value: ngapType.RRCEstablishmentCausePresentMoData}))
+	emergency := mustDecodeNgap(t, initialUEMessageWithCause(t, 2,
+		ngapType.RRCEstablishmentCause{Value: ngapType.RRCEstablishmentCausePresentEmergency}))
+
+	if got := admitInitialUE(overloaded, nil, emergency); got != overloaded {
+		t.Errorf("UE the overload action permits must stay. got = %v", got)
+	}
+	for i := 0; i < 2; i++ {
+		if got := admitInitialUE(overloaded, nil, moData); got != other {
+			t.Errorf("UE the overload action rejects must be steered to an eligible AMF. got = %v, want = %v", got, other)
+		}
+	}
+	other.overload.start(ngapType.OverloadAction{Value: ngapType.OverloadActionPresentRejectRrcCrSignalling}, 0)
+	if got := admitInitialUE(overloaded, nil, moData); got != nil {
+		t.Errorf("UE must be rejected when every eligible AMF is overloaded. got = %v", got)
+	}
+}
+
+func Test_RouteMessageEveryAmfOverloaded(t *testing.T) {
+	defer func(s Scheduler) { scheduler = s }(scheduler)
+	scheduler = &roundRobinScheduler{}
+	ctx := context.Sctplb_Self()
+	b := readyGrpcServer("10.6.0.5")
+	ctx.AddNF(b)
+	defer ctx.DeleteNF(b)
+	b.overload.start(ngapType.OverloadAction{Value: ngapType.OverloadActionPresentRejectRrcCrSignalling}, 0)
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	ran := &context.Ran{Conn: local, Log: logger.RanLog}
+	received := make(chan *ngapMessage, 1)
+	go func() {
+		buf := make([]byte, 1024)
+		n, err := remote.Read(buf)
+		if err != nil {
+			return
+		}
+		m, err := decodeNgap(buf[:n])
+		if err != nil {
+			t.Errorf("answer can not be decoded: %v", err)
+			return
+		}
+		received <- m
+	}()
+
+	msg := initialUEMessageWithCause(t, 9,
+		ngapType.RRCEstablishmentCause{Value: ngapType.RRCEstablishmentCausePresentMoSignalling})
+	if !routeMessage(ran, msg, mustDecodeNgap(t, msg), nil) {
+		t.Fatalf("rejected InitialUEMessage must not be held")
+	}
+	select {
+	case m := <-received:
+		if !m.initiating(ngapType.InitiatingMessagePresentErrorIndication) {
+			t.Fatalf("InitialUEMessage was not answered with an error indication")
+		}
+		if m.hasAmfUeNgapId || !m.hasRanUeNgapId || m.ranUeNgapId != 9 {
+			t.Errorf("error indication UE NGAP IDs mismatch. got = %v/%d", m.hasAmfUeNgapId, m.ranUeNgapId)
+		}
+		var cause *ngapType.Cause
+		for _, ie := range m.pdu.InitiatingMessage.Value.ErrorIndication.ProtocolIEs.List {
+			if ie.Value.Present == ngapType.ErrorIndicationIEsPresentCause {
+				cause = ie.Value.Cause
+			}
+		}
+		if cause == nil || cause.Misc == nil || cause.Misc.Value != ngapType.CauseMiscPresentControlProcessingOverload {
+			t.Errorf("error indication cause mismatch. got = %+v", cause)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("gNB got no answer")
+	}
+}
@@ -0,0 +1,156 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/logger"
+	gClient "github.com/omec-project/sctplb/sdcoreAmfServer"
+)
+
+// overflow policies of the backend send queue
+const (
+	OverflowBlock      = "block"
+	OverflowDropOldest = "drop-oldest"
+	OverflowReject     = "reject"
+)
+
+const (
+	defaultSendQueueDepth = 1024
+	defaultBlockTimeout   = time.Second
+)
+
+var (
+	ErrQueueFull       = errors.New("backend send queue is full")
+	ErrBackendClosed   = errors.New("backend is closed")
+	ErrBackendNotReady = errors.New("backend is not ready")
+)
+
+// sendQueue is the bounded outbound queue of a backend. Any goroutine may push
+// to it, but only the backend writer pops from it, so messages reach the gRPC
+// stream one at a time.
+type sendQueue struct {
+	ch       chan *gClient.SctplbMessage
+	overflow string
+	// blockTimeout bounds how long the block policy waits for room
+	blockTimeout time.Duration
+	enqueued     atomic.Uint64
+	sent         atomic.Uint64
+	dropped      atomic.Uint64
+	rejected     atomic.Uint64
+}
+
+type QueueStats struct {
+	Depth    int    `json:"depth"`
+	Capacity int    `json:"capacity"`
+	Enqueued uint64 `json:"enqueued"`
+	Sent     uint64 `json:"sent"`
+	Dropped  uint64 `json:"dropped"`
+	Rejected uint64 `json:"rejected"`
+}
+
+func newSendQueue(cfg *config.SendQueue) *sendQueue {
+	depth := defaultSendQueueDepth
+	overflow := OverflowBlock
+	blockTimeout := defaultBlockTimeout
+	if cfg != nil {
+		if cfg.Depth > 0 {
+			depth = cfg.Depth
+		}
+		if cfg.BlockTimeout > 0 {
+			blockTimeout = cfg.BlockTimeout
+		}
+		switch cfg.Overflow {
+		case "":
+		case OverflowBlock, OverflowDropOldest, OverflowReject:
+			overflow = cfg.Overflow
+		default:
+			logger.GrpcLog.Warnf("unsupported send queue overflow policy %q, using %q", cfg.Overflow, overflow)
+		}
+	}
+	return &sendQueue{
+		ch:           make(chan *gClient.SctplbMessage, depth),
+		overflow:     overflow,
+		blockTimeout: blockTimeout,
+	}
+}
+
+// push adds msg to the queue and applies the overflow policy when it is full:
+// block waits for room until stop or session is closed or blockTimeout
+// expired, drop-oldest discards the oldest queued messages and reject fails
+// with ErrQueueFull. session is closed when the session of the backend ends.
+func (q *sendQueue) push(msg *gClient.SctplbMessage, stop, session <-chan struct{}) error {
+	select {
+	case q.ch <- msg:
+		q.enqueued.Add(1)
+		return nil
+	default:
+	}
+	switch q.overflow {
+	case OverflowReject:
+		q.rejected.Add(1)
+		return ErrQueueFull
+	case OverflowDropOldest:
+		for {
+			select {
+			case q.ch <- msg:
+				q.enqueued.Add(1)
+				return nil
+			default:
+			}
+			select {
+			case <-q.ch:
+				q.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		timer := time.NewTimer(q.blockTimeout)
+		defer timer.Stop()
+		select {
+		case q.ch <- msg:
+			q.enqueued.Add(1)
+			return nil
+		case <-stop:
+			q.rejected.Add(1)
+			return ErrBackendClosed
+		case <-session:
+			q.rejected.Add(1)
+			return ErrBackendNotReady
+		case <-timer.C:
+			q.rejected.Add(1)
+			return ErrQueueFull
+		}
+	}
+}
+
+// discard drops the queued messages, it returns how many were dropped
+func (q *sendQueue) discard() int {
+	n := 0
+	for {
+		select {
+		case <-q.ch:
+			q.dropped.Add(1)
+			n++
+		default:
+			return n
+		}
+	}
+}
+
+func (q *sendQueue) stats() QueueStats {
+	return QueueStats{
+		Depth:    len(q.ch),
+		Capacity: cap(q.ch),
+		Enqueued: q.enqueued.Load(),
+		Sent:     q.sent.Load(),
+		Dropped:  q.dropped.Load(),
+		Rejected: q.rejected.Load(),
+	}
+}
@@ -0,0 +1,124 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/omec-project/sctplb/config"
+	gClient "github.com/omec-project/sctplb/sdcoreAmfServer"
+)
+
+func queueMsg(id string) *gClient.SctplbMessage {
+	return &gClient.SctplbMessage{GnbId: id}
+}
+
+func Test_SendQueueReject(t *testing.T) {
+	q := newSendQueue(&config.SendQueue{Depth: 2, Overflow: OverflowReject})
+	for _, id := range []string{"1", "2"} {
+		if err := q.push(queueMsg(id), nil, nil); err != nil {
+			t.Fatalf("push %s failed: %v", id, err)
+		}
+	}
+	if err := q.push(queueMsg("3"), nil, nil); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("push to full queue error mismatch. got = %v, want = %v", err, ErrQueueFull)
+	}
+	stats := q.stats()
+	if stats.Depth != 2 || stats.Capacity != 2 || stats.Enqueued != 2 || stats.Rejected != 1 {
+		t.Errorf("queue stats mismatch. got = %+v", stats)
+	}
+}
+
+func Test_SendQueueDropOldest(t *testing.T) {
+	q := newSendQueue(&config.SendQueue{Depth: 2, Overflow: OverflowDropOldest})
+	for _, id := range []string{"1", "2", "3"} {
+		if err := q.push(queueMsg(id), nil, nil); err != nil {
+			t.Fatalf("push %s failed: %v", id, err)
+		}
+	}
+	for _, want := range []string{"2", "3"} {
+		if got := (<-q.ch).GnbId; got != want {
+			t.Errorf("queued message mismatch. got = %s, want = %s", got, want)
+		}
+	}
+	if stats := q.stats(); stats.Dropped != 1 || stats.Enqueued != 3 {
+		t.Errorf("queue stats mismatch. got = %+v", stats)
+	}
+}
+
+func Test_SendQueueBlock(t *testing.T) {
+	q := newSendQueue(&config.SendQueue{Depth: 1})
+	if err := q.push(queueMsg("1"), nil, nil); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+
+	pushed := make(chan error, 1)
+	go func() { pushed <- q.push(queueMsg("2"), nil, nil) }()
+	select {
+	case err := <-pushed:
+		t.Fatalf("push to full queue returned early: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	<-q.ch
+	if err := <-pushed; err != nil {
+		t.Errorf("blocked push failed: %v", err)
+	}
+
+	stop := make(chan struct{})
+	close(stop)
+	if err := q.push(queueMsg("3"), stop, nil); !errors.Is(err, ErrBackendClosed) {
+		t.Errorf("push to stopped queue error mismatch. got = %v, want = %v", err, ErrBackendClosed)
+	}
+}
+
+func Test_SendQueueBlockEnds(t *testing.T) {
+	q := newSendQueue(&config.SendQueue{Depth: 1, BlockTimeout: 50 * time.Millisecond})
+	if err := q.push(queueMsg("1"), nil, nil); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+
+	// a blocked push gives up once the session ended
+	session := make(chan struct{})
+	pushed := make(chan error, 1)
+	go func() { pushed <- q.push(queueMsg("2"), nil, session) }()
+	close(session)
+	select {
+	case err := <-pushed:
+		if !errors.Is(err, ErrBackendNotReady) {
+			t.Errorf("push after session end error mismatch. got = %v, want = %v", err, ErrBackendNotReady)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked push was not woken by the session end")
+	}
+
+	// and once the block timeout expired
+	start := time.Now()
+	if err := q.push(queueMsg("3"), nil, nil); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("push after block timeout error mismatch. got = %v, want = %v", err, ErrQueueFull)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("push gave up before the block timeout, after %v", elapsed)
+	}
+	if stats := q.stats(); stats.Rejected != 2 {
+		t.Errorf("queue stats mismatch. got = %+v", stats)
+	}
+}
+
+func Test_SendQueueDiscard(t *testing.T) {
+	q := newSendQueue(&config.SendQueue{Depth: 4})
+	for _, id := range []string{"1", "2", "3"} {
+		if err := q.push(queueMsg(id), nil, nil); err != nil {
+			t.Fatalf("push %s failed: %v", id, err)
+		}
+	}
+	if n := q.discard(); n != 3 {
+		t.Errorf("discarded messages mismatch. got = %d, want = 3", n)
+	}
+	if stats := q.stats(); stats.Depth != 0 || stats.Dropped != 3 {
+		t.Errorf("queue stats mismatch. got = %+v", stats)
+	}
+}
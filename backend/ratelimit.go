@@ -0,0 +1,353 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ishidawataru/sctp"
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/context"
+)
+
+// actions taken on a message over the rate limit of its gNB
+const (
+	// RateLimitDrop drops the message
+	RateLimitDrop = "drop"
+	// RateLimitDelay waits for the bucket to refill, and drops the message
+	// when that takes longer than the maximum delay
+	RateLimitDelay = "delay"
+	// RateLimitReject answers the message locally with cause control
+	// processing overload
+	RateLimitReject = "reject"
+)
+
+const defaultRateLimitMaxDelay = time.Second
+
+// RateLimitStats are the counters of the rate limiting of the gNBs
+type RateLimitStats struct {
+	Dropped  uint64              `json:"dropped"`
+	Delayed  uint64              `json:"delayed"`
+	Rejected uint64              `json:"rejected"`
+	Gnbs     []GnbRateLimitStats `json:"gnbs,omitempty"`
+}
+
+// GnbRateLimitStats counts the messages of a gNB that were over its limits
+type GnbRateLimitStats struct {
+	Address              string `json:"address"`
+	GnbId                string `json:"gnbId,omitempty"`
+	SignallingViolations uint64 `json:"signallingViolations"`
+	InitialUEViolations  uint64 `json:"initialUEViolations"`
+}
+
+// tokenBucket admits rate messages per second on average and bursts of up to
+// burst messages, a nil bucket does not limit
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(l *config.RateLimit, now time.Time) *tokenBucket {
+	if l == nil || l.Rate <= 0 {
+		return nil
+	}
+	burst := max(float64(l.Burst), 1)
+	return &tokenBucket{rate: l.Rate, burst: burst, tokens: burst, last: now}
+}
+
+// carry keeps the tokens left in old, a gNB whose limits changed does not
+// get a second burst
+func (b *tokenBucket) carry(old *tokenBucket) {
+	if b == nil || old == nil {
+		return
+	}
+	b.tokens, b.last = min(old.tokens, b.burst), old.last
+}
+
+// reserve takes a token for a message sent once wait passed, ok is false and
+// no token is taken when the message would wait longer than maxWait
+func (b *tokenBucket) reserve(now time.Time, maxWait time.Duration) (wait time.Duration, ok bool) {
+	if b == nil {
+		return 0, true
+	}
+	if now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+	if b.tokens < 1 {
+		wait = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	}
+	if wait > maxWait {
+		return wait, false
+	}
+	b.tokens--
+	return wait, true
+}
+
+// gnbRateLimit is a parsed config.GnbRateLimit
+type gnbRateLimit struct {
+	gnbId      string
+	network    *net.IPNet
+	signalling *config.RateLimit
+	initialUE  *config.RateLimit
+}
+
+// gnbLimiter holds the buckets of one association
+type gnbLimiter struct {
+	// gnbId is the GnbId the limits were chosen with, they are chosen again
+	// once the GnbId of the gNB is learnt
+	gnbId                string
+	signalling           *tokenBucket
+	initialUE            *tokenBucket
+	signallingViolations uint64
+	initialUEViolations  uint64
+}
+
+// rateLimiter limits the uplink messages of each gNB with a token bucket for
+// its non-UE-associated signalling and one for its InitialUEMessages
+type rateLimiter struct {
+	mu         sync.Mutex
+	enabled    bool
+	action     string
+	maxDelay   time.Duration
+	signalling *config.RateLimit
+	initialUE  *config.RateLimit
+	gnbs       []gnbRateLimit
+	limiters   map[*context.Ran]*gnbLimiter
+	dropped    atomic.Uint64
+	delayed    atomic.Uint64
+	rejected   atomic.Uint64
+}
+
+var rateLimits = &rateLimiter{
+	action:   RateLimitDrop,
+	maxDelay: defaultRateLimitMaxDelay,
+	limiters: make(map[*context.Ran]*gnbLimiter),
+}
+
+// SetRateLimiting configures the rate limits of the gNBs, they are disabled
+// unless cfg sets one. It has to be called before the SCTP service is
+// started.
+func SetRateLimiting(cfg *config.RateLimiting) error {
+	action, maxDelay := RateLimitDrop, defaultRateLimitMaxDelay
+	var signalling, initialUE *config.RateLimit
+	var gnbs []gnbRateLimit
+	enabled := false
+	if cfg != nil {
+		if cfg.Action != "" {
+			action = cfg.Action
+		}
+		if cfg.MaxDelay > 0 {
+			maxDelay = cfg.MaxDelay
+		}
+		signalling, initialUE = cfg.Signalling, cfg.InitialUE
+		enabled = limits(signalling) || limits(initialUE)
+		for _, g := range cfg.Gnbs {
+			l := gnbRateLimit{gnbId: g.GnbId, signalling: g.Signalling, initialUE: g.InitialUE}
+			if g.Cidr != "" {
+				_, network, err := net.ParseCIDR(g.Cidr)
+				if err != nil {
+					return fmt.Errorf("invalid gNB rate limit cidr: %w", err)
+				}
+				l.network = network
+			}
+			if l.gnbId == "" && l.network == nil {
+				return fmt.Errorf("gNB rate limit without gnbId or cidr")
+			}
+			enabled = enabled || limits(l.signalling) || limits(l.initialUE)
+			gnbs = append(gnbs, l)
+		}
+	}
+	switch action {
+	case RateLimitDrop, RateLimitDelay, RateLimitReject:
+	default:
+		return fmt.Errorf("unsupported rate limit action: %s", action)
+	}
+	rateLimits.mu.Lock()
+	defer rateLimits.mu.Unlock()
+	rateLimits.enabled, rateLimits.action, rateLimits.maxDelay = enabled, action, maxDelay
+	rateLimits.signalling, rateLimits.initialUE, rateLimits.gnbs = signalling, initialUE, gnbs
+	rateLimits.limiters = make(map[*context.Ran]*gnbLimiter)
+	rateLimits.dropped.Store(0)
+	rateLimits.delayed.Store(0)
+	rateLimits.rejected.Store(0)
+	return nil
+}
+
+func limits(l *config.RateLimit) bool {
+	return l != nil && l.Rate > 0
+}
+
+// RateLimitStatus returns the counters of the rate limiting
+func RateLimitStatus() RateLimitStats {
+	rateLimits.mu.Lock()
+	var gnbs []GnbRateLimitStats
+	for ran, l := range rateLimits.limiters {
+		gnbs = append(gnbs, GnbRateLimitStats{
+			Address:              ran.GnbIp,
+			GnbId:                l.gnbId,
+			SignallingViolations: l.signallingViolations,
+			InitialUEViolations:  l.initialUEViolations,
+		})
+	}
+	rateLimits.mu.Unlock()
+	return RateLimitStats{
+		Dropped:  rateLimits.dropped.Load(),
+		Delayed:  rateLimits.delayed.Load(),
+		Rejected: rateLimits.rejected.Load(),
+		Gnbs:     gnbs,
+	}
+}
+
+// admit applies the rate limits of ran to the decoded uplink message m, nil
+// if it can not be decoded, it reports whether the message may be sent on. A
+// message over the limit is delayed, dropped or answered depending on the
+// action.
+func (r *rateLimiter) admit(ran *context.Ran, m *ngapMessage) bool {
+	r.mu.Lock()
+	enabled := r.enabled
+	r.mu.Unlock()
+	if !enabled {
+		return true
+	}
+	var initialUE bool
+	switch {
+	case m == nil:
+		// limited as signalling, it takes the path of any other message
+	case m.isInitialUEMessage():
+		initialUE = true
+	case m.hasAmfUeNgapId || m.hasRanUeNgapId:
+		return true
+	}
+
+	r.mu.Lock()
+	l := r.limiter(ran)
+	bucket := l.signalling
+	if initialUE {
+		bucket = l.initialUE
+	}
+	maxWait := time.Duration(0)
+	if r.action == RateLimitDelay {
+		maxWait = r.maxDelay
+	}
+	wait, ok := bucket.reserve(time.Now(), maxWait)
+	if wait > 0 {
+		if initialUE {
+			l.initialUEViolations++
+		} else {
+			l.signallingViolations++
+		}
+	}
+	action := r.action
+	r.mu.Unlock()
+
+	switch {
+	case ok && wait > 0:
+		r.delayed.Add(1)
+		time.Sleep(wait)
+		return true
+	case ok:
+		return true
+	case action == RateLimitReject:
+		r.rejected.Add(1)
+		ran.Log.Warnln("rejecting message over the rate limit of the gNB")
+		rejectMessage(ran, m, overloadCause())
+		return false
+	default:
+		r.dropped.Add(1)
+		ran.Log.Warnln("dropping message over the rate limit of the gNB")
+		return false
+	}
+}
+
+// limiter returns the buckets of ran, it is called with the lock held
+func (r *rateLimiter) limiter(ran *context.Ran) *gnbLimiter {
+	gnbId := ""
+	if ran.RanId != nil {
+		gnbId = *ran.RanId
+	}
+	l := r.limiters[ran]
+	if l != nil && l.gnbId == gnbId {
+		return l
+	}
+	signalling, initialUE := r.signalling, r.initialUE
+	if g := r.override(gnbId, ranIPs(ran)); g != nil {
+		if g.signalling != nil {
+			signalling = g.signalling
+		}
+		if g.initialUE != nil {
+			initialUE = g.initialUE
+		}
+	}
+	now := time.Now()
+	next := &gnbLimiter{
+		gnbId:      gnbId,
+		signalling: newTokenBucket(signalling, now),
+		initialUE:  newTokenBucket(initialUE, now),
+	}
+	if l != nil {
+		next.signalling.carry(l.signalling)
+		next.initialUE.carry(l.initialUE)
+		next.signallingViolations, next.initialUEViolations = l.signallingViolations, l.initialUEViolations
+	}
+	r.limiters[ran] = next
+	return next
+}
+
+// override returns the first override matching the gNB with gnbId and
+// addresses ips
+func (r *rateLimiter) override(gnbId string, ips []net.IP) *gnbRateLimit {
+	for i := range r.gnbs {
+		g := &r.gnbs[i]
+		if g.gnbId != "" && g.gnbId == gnbId {
+			return g
+		}
+		if g.network != nil && containsAny(g.network, ips) {
+			return g
+		}
+	}
+	return nil
+}
+
+// release drops the buckets of a disconnected gNB
+func (r *rateLimiter) release(ran *context.Ran) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.limiters, ran)
+}
+
+// ranIPs returns the addresses of the association of ran
+func ranIPs(ran *context.Ran) []net.IP {
+	if ran.Conn == nil {
+		return nil
+	}
+	switch addr := ran.Conn.RemoteAddr().(type) {
+	case *sctp.SCTPAddr:
+		ips := make([]net.IP, 0, len(addr.IPAddrs))
+		for _, ip := range addr.IPAddrs {
+			ips = append(ips, ip.IP)
+		}
+		return ips
+	case *net.TCPAddr:
+		return []net.IP{addr.IP}
+	default:
+		return nil
+	}
+}
+
+func containsAny(network *net.IPNet, ips []net.IP) bool {
+	for _, ip := range ips {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/omec-project/ngap/v2/ngapType"
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/context"
+	"github.com/omec-project/sctplb/logger"
+)
+
+// addrConn is a connection with the remote address of a gNB
+type addrConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c addrConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func Test_TokenBucket(t *testing.T) {
+	now := time.Unix(1000, 0)
+	b := newTokenBucket(&config.RateLimit{Rate: 10, Burst: 2}, now)
+	for i := 0; i < 2; i++ {
+		if wait, ok := b.reserve(now, 0); !ok || wait != 0 {
+			t.Fatalf("burst message %d was limited", i)
+		}
+	}
+	if _, ok := b.reserve(now, 0); ok {
+		t.Fatalf("message over the burst was admitted")
+	}
+	// a message may wait for the next token
+	if wait, ok := b.reserve(now, time.Second); !ok || wait != 100*time.Millisecond {
+		t.Fatalf("reserve mismatch. got = %v/%v, want = %v/true", wait, ok, 100*time.Millisecond)
+	}
+	if wait, ok := b.reserve(now.Add(100*time.Millisecond), time.Second); !ok || wait != 100*time.Millisecond {
+		t.Fatalf("reserve after the reserved token mismatch. got = %v/%v", wait, ok)
+	}
+	// it refills up to the burst
+	if _, ok := b.reserve(now.Add(time.Hour), 0); !ok || b.tokens != 1 {
+		t.Fatalf("bucket did not refill to its burst. tokens = %v", b.tokens)
+	}
+	if wait, ok := newTokenBucket(&config.RateLimit{}, now).reserve(now, 0); !ok || wait != 0 {
+		t.Errorf("bucket without rate limited a message")
+	}
+}
+
+func Test_SetRateLimiting(t *testing.T) {
+	defer func() {
+		if err := SetRateLimiting(nil); err != nil {
+			t.Error(err)
+		}
+	}()
+	tests := []struct {
+		name    string
+		cfg     *config.RateLimiting
+		wantErr bool
+	}{
+		{name: "disabled", cfg: nil},
+		{name: "delay", cfg: &config.RateLimiting{Action: RateLimitDelay, Signalling: &config.RateLimit{Rate: 1}}},
+		{name: "unknown action", cfg: &config.RateLimiting{Action: "slow"}, wantErr: true},
+		{name: "invalid cidr", cfg: &config.RateLimiting{Gnbs: []config.GnbRateLimit{{Cidr: "10.0.0.0"}}}, wantErr: true},
+		{name: "no match", cfg: &config.RateLimiting{Gnbs: []config.GnbRateLimit{{}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := SetRateLimiting(tt.cfg); (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_RateLimitOverride(t *testing.T) {
+	defer SetRateLimiting(nil)
+	if err := SetRateLimiting(&config.RateLimiting{
+		Signalling: &config.RateLimit{Rate: 1},
+		InitialUE:  &config.RateLimit{Rate: 2},
+		Gnbs: []config.GnbRateLimit{
+			{GnbId: "208:93:000102", Signalling: &config.RateLimit{Rate: 3}},
+			{Cidr: "10.10.0.0/16", InitialUE: &config.RateLimit{Rate: 4}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ran := &context.Ran{Conn: addrConn{remote: &net.TCPAddr{IP: net.ParseIP("10.10.1.1")}}}
+
+	rateLimits.mu.Lock()
+	defer rateLimits.mu.Unlock()
+	l := rateLimits.limiter(ran)
+	if l.signalling.rate != 1 || l.initialUE.rate != 4 {
+		t.Errorf("cidr override mismatch. got = %v/%v, want = 1/4", l.signalling.rate, l.initialUE.rate)
+	}
+	// the limits are chosen again once the GnbId is known
+	l.signallingViolations = 7
+	l.signalling.tokens, l.initialUE.tokens = 0, 0.5
+	ran.SetRanId("208:93:000102")
+	l = rateLimits.limiter(ran)
+	if l.signalling.rate != 3 || l.initialUE.rate != 2 {
+		t.Errorf("gnbId override mismatch. got = %v/%v, want = 3/2", l.signalling.rate, l.initialUE.rate)
+	}
+	if l.signallingViolations != 7 {
+		t.Errorf("violations were lost with the new limits")
+	}
+	if l.signalling.tokens != 0 || l.initialUE.tokens != 0.5 {
+		t.Errorf("tokens were refilled with the new limits. got = %v/%v, want = 0/0.5", l.signalling.tokens, l.initialUE.tokens)
+	}
+}
+
+func Test_RateLimitAdmit(t *testing.T) {
+	defer SetRateLimiting(nil)
+	if err := SetRateLimiting(&config.RateLimiting{
+		Action:     RateLimitReject,
+		Signalling: &config.RateLimit{Rate: 0.001},
+		InitialUE:  &config.RateLimit{Rate: 0.001, Burst: 2},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	local, remote := net.Pipe()
+	defer remote.Close()
+	ran := &context.Ran{Conn: local, Log: logger.RanLog}
+	received := make(chan *ngapMessage, 4)
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := remote.Read(buf)
+			if err != nil {
+				return
+			}
+			m, err := decodeNgap(buf[:n])
+			if err != nil {
+				t.Errorf("answer can not be decoded: %v", err)
+				return
+			}
+			received <- m
+		}
+	}()
+
+	setup := mustDecodeNgap(t, ngSetupRequest(t, []byte{0, 0, 1}))
+	if !rateLimits.admit(ran, setup) {
+		t.Fatalf("first NG Setup Request was limited")
+	}
+	if rateLimits.admit(ran, setup) {
+		t.Fatalf("NG Setup Request over the limit was admitted")
+	}
+	select {
+	case m := <-received:
+		if m.pdu.Present != ngapType.NGAPPDUPresentUnsuccessfulOutcome {
+			t.Errorf("NG Setup Request over the limit was not answered with a failure")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("gNB got no answer")
+	}
+
+	// InitialUEMessages have a bucket of their own, the other messages of
+	// UEs are not limited
+	for i := int64(0); i < 2; i++ {
+		if !rateLimits.admit(ran, mustDecodeNgap(t, initialUEMessage(t, i))) {
+			t.Errorf("InitialUEMessage %d was limited", i)
+		}
+	}
+	for i := int64(0); i < 3; i++ {
+		if !rateLimits.admit(ran, mustDecodeNgap(t, uplinkNASTransport(t, 1, i))) {
+			t.Errorf("uplink NAS transport %d was limited", i)
+		}
+	}
+
+	s := RateLimitStatus()
+	if s.Rejected != 1 || len(s.Gnbs) != 1 || s.Gnbs[0].SignallingViolations != 1 || s.Gnbs[0].InitialUEViolations != 0 {
+		t.Errorf("status mismatch. got = %+v", s)
+	}
+	rateLimits.release(ran)
+	if s := RateLimitStatus(); len(s.Gnbs) != 0 {
+		t.Errorf("released gNB is still limited")
+	}
+}
+
+func Test_RateLimitDelay(t *testing.T) {
+	defer SetRateLimiting(nil)
+	if err := SetRateLimiting(&config.RateLimiting{
+		Action:     RateLimitDelay,
+		MaxDelay:   100 * time.Millisecond,
+		Signalling: &config.RateLimit{Rate: 20},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ran := &context.Ran{Log: logger.RanLog}
+	setup := mustDecodeNgap(t, ngSetupRequest(t, []byte{0, 0, 1}))
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if !rateLimits.admit(ran, setup) {
+			t.Fatalf("message %d was dropped", i)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("second message was not delayed, took %v", elapsed)
+	}
+	// a message that would wait longer than MaxDelay is dropped
+	rateLimits.mu.Lock()
+	rateLimits.limiters[ran].signalling.tokens = -10
+	rateLimits.mu.Unlock()
+	if rateLimits.admit(ran, setup) {
+		t.Errorf("message over MaxDelay was admitted")
+	}
+	if s := RateLimitStatus(); s.Delayed != 1 || s.Dropped != 1 {
+		t.Errorf("status mismatch. got = %+v", s)
+	}
+}
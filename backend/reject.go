@@ -0,0 +1,171 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"time"
+
+	"github.com/omec-project/ngap/v2"
+	"github.com/omec-project/ngap/v2/ngapType"
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/context"
+	gClient "github.com/omec-project/sctplb/sdcoreAmfServer"
+)
+
+const defaultTimeToWait = 10 * time.Second
+
+// timeToWait is the Time To Wait of the NG Setup Failures sctplb sends itself
+var timeToWait = defaultTimeToWait
+
+// SetNoBackend configures the answer of sctplb to a gNB while no backend is
+// available, it has to be called before the SCTP service is started
+func SetNoBackend(cfg *config.NoBackend) {
+	timeToWait = defaultTimeToWait
+	if cfg != nil && cfg.TimeToWait > 0 {
+		timeToWait = cfg.TimeToWait
+	}
+}
+
+// rejectNoBackend answers a message of ran no backend is available for: an
+// NG Setup Request gets an NG Setup Failure, so the gNB retries after the
+// Time To Wait instead of its own NG Setup timer, any other message an Error
+// Indication. Messages that can not be decoded and Error Indications are not
+// answered.
+func rejectNoBackend(ran *context.Ran, m *ngapMessage) {
+	rejectMessage(ran, m, noBackendCause())
+}
+
+// rejectMessage answers a message of ran sctplb does not forward with cause,
+// as rejectNoBackend does
+func rejectMessage(ran *context.Ran, m *ngapMessage, cause ngapType.Cause) {
+	if m == nil || m.initiating(ngapType.InitiatingMessagePresentErrorIndication) {
+		return
+	}
+	var pdu ngapType.NGAPPDU
+	if m.initiating(ngapType.InitiatingMessagePresentNGSetup) {
+		pdu = ngSetupFailure(cause, timeToWaitValue(timeToWait))
+	} else {
+		pdu = errorIndication(m, cause)
+	}
+	msg, err := ngap.Encoder(pdu)
+	if err != nil {
+		ran.Log.Errorf("can not encode NGAP message: %v", err)
+		return
+	}
+	if err := writeToRan(ran, &gClient.AmfMessage{Msg: msg}, m); err != nil {
+		ran.Log.Warnf("can not answer the gNB: %v", err)
+	}
+}
+
+func noBackendCause() ngapType.Cause {
+	return ngapType.Cause{
+		Present: ngapType.CausePresentMisc,
+		Misc:    &ngapType.CauseMisc{Value: ngapType.CauseMiscPresentUnspecified},
+	}
+}
+
+func overloadCause() ngapType.Cause {
+	return ngapType.Cause{
+		Present: ngapType.CausePresentMisc,
+		Misc:    &ngapType.CauseMisc{Value: ngapType.CauseMiscPresentControlProcessingOverload},
+	}
+}
+
+// timeToWaitValue returns the shortest NGAP Time To Wait that is at least d
+func timeToWaitValue(d time.Duration) ngapType.TimeToWait {
+	switch {
+	case d <= time.Second:
+		return ngapType.TimeToWait{Value: ngapType.TimeToWaitPresentV1s}
+	case d <= 2*time.Second:
+		return ngapType.TimeToWait{Value: ngapType.TimeToWaitPresentV2s}
+	case d <= 5*time.Second:
+		return ngapType.TimeToWait{Value: ngapType.TimeToWaitPresentV5s}
+	case d <= 10*time.Second:
+		return ngapType.TimeToWait{Value: ngapType.TimeToWaitPresentV10s}
+	case d <= 20*time.Second:
+		return ngapType.TimeToWait{Value: ngapType.TimeToWaitPresentV20s}
+	default:
+		return ngapType.TimeToWait{Value: ngapType.TimeToWaitPresentV60s}
+	}
+}
+
+func ngSetupFailure(cause ngapType.Cause, wait ngapType.TimeToWait) ngapType.NGAPPDU {
+	failure := ngapType.NGSetupFailure{}
+	failure.ProtocolIEs.List = []ngapType.NGSetupFailureIEs{
+		{
+			Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDCause},
+			Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+			Value: ngapType.NGSetupFailureIEsValue{
+				Present: ngapType.NGSetupFailureIEsPresentCause,
+				Cause:   &cause,
+			},
+		},
+		{
+			Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDTimeToWait},
+			Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+			Value: ngapType.NGSetupFailureIEsValue{
+				Present:    ngapType.NGSetupFailureIEsPresentTimeToWait,
+				TimeToWait: &wait,
+			},
+		},
+	}
+	return ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentUnsuccessfulOutcome,
+		UnsuccessfulOutcome: &ngapType.UnsuccessfulOutcome{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeNGSetup},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+			Value: ngapType.UnsuccessfulOutcomeValue{
+				Present: ngapType.UnsuccessfulOutcomePresentNGSetup,
+				NGSetup: &failure,
+			},
+		},
+	}
+}
+
+// errorIndication reports cause for the message m, it carries the UE NGAP IDs
+// of m that are known, such as the RAN UE NGAP ID alone of an
+// InitialUEMessage
+func errorIndication(m *ngapMessage, cause ngapType.Cause) ngapType.NGAPPDU {
+	indication := ngapType.ErrorIndication{}
+	if m.hasAmfUeNgapId {
+		indication.ProtocolIEs.List = append(indication.ProtocolIEs.List, ngapType.ErrorIndicationIEs{
+			Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDAMFUENGAPID},
+			Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+			Value: ngapType.ErrorIndicationIEsValue{
+				Present:     ngapType.ErrorIndicationIEsPresentAMFUENGAPID,
+				AMFUENGAPID: &ngapType.AMFUENGAPID{Value: m.amfUeNgapId},
+			},
+		})
+	}
+	if m.hasRanUeNgapId {
+		indication.ProtocolIEs.List = append(indication.ProtocolIEs.List, ngapType.ErrorIndicationIEs{
+			Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDRANUENGAPID},
+			Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+			Value: ngapType.ErrorIndicationIEsValue{
+				Present:     ngapType.ErrorIndicationIEsPresentRANUENGAPID,
+				RANUENGAPID: &ngapType.RANUENGAPID{Value: m.ranUeNgapId},
+			},
+		})
+	}
+	indication.ProtocolIEs.List = append(indication.ProtocolIEs.List, ngapType.ErrorIndicationIEs{
+		Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDCause},
+		Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+		Value: ngapType.ErrorIndicationIEsValue{
+			Present: ngapType.ErrorIndicationIEsPresentCause,
+			Cause:   &cause,
+		},
+	})
+	return ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentInitiatingMessage,
+		InitiatingMessage: &ngapType.InitiatingMessage{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeErrorIndication},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+			Value: ngapType.InitiatingMessageValue{
+				Present:         ngapType.InitiatingMessagePresentErrorIndication,
+				ErrorIndication: &indication,
+			},
+		},
+	}
+}
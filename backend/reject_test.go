@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/omec-project/ngap/v2/ngapType"
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/context"
+	"github.com/omec-project/sctplb/logger"
+)
+
+func Test_TimeToWaitValue(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want ngapType.TimeToWait
+	}{
+		{d: 0, want: ngapType.TimeToWait{Value: ngapType.TimeToWaitPresentV1s}},
+		{d: 2 * time.Second, want: ngapType.TimeToWait{Value: ngapType.TimeToWaitPresentV2s}},
+		{d: 3 * time.Second, want: ngapType.TimeToWait{Value: ngapType.TimeToWaitPresentV5s}},
+		{d: 10 * time.Second, want: ngapType.TimeToWait{Value: ngapType.TimeToWaitPresentV10s}},
+		{d: 15 * time.Second, want: ngapType.TimeToWait{Value: ngapType.TimeToWaitPresentV20s}},
+		{d: time.Hour, want: ngapType.TimeToWait{Value: ngapType.TimeToWaitPresentV60s}},
+	}
+	for _, tt := range tests {
+		if got := timeToWaitValue(tt.d); got != tt.want {
+			t.Errorf("timeToWaitValue(%v) = %v, want %v", tt.d, got.Value, tt.want.Value)
+		}
+	}
+}
+
+func Test_RejectNoBackend(t *testing.T) {
+	SetNoBackend(&config.NoBackend{TimeToWait: 20 * time.Second})
+	defer SetNoBackend(nil)
+	local, remote := net.Pipe()
+	defer remote.Close()
+	ran := &context.Ran{Conn: local, Log: logger.RanLog}
+	received := make(chan *ngapMessage, 4)
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := remote.Read(buf)
+			if err != nil {
+				return
+			}
+			m, err := decodeNgap(buf[:n])
+			if err != nil {
+				t.Errorf("answer can not be decoded: %v", err)
+				return
+			}
+			received <- m
+		}
+	}()
+	answer := func() *ngapMessage {
+		select {
+		case m := <-received:
+			return m
+		case <-time.After(time.Second):
+			t.Fatal("gNB got no answer")
+			return nil
+		}
+	}
+
+	rejectNoBackend(ran, mustDecodeNgap(t, ngSetupRequest(t, []byte{0, 0, 1})))
+	m := answer()
+	if m.pdu.Present != ngapType.NGAPPDUPresentUnsuccessfulOutcome ||
+		m.pdu.UnsuccessfulOutcome.Value.Present != ngapType.UnsuccessfulOutcomePresentNGSetup {
+		t.Fatalf("NG Setup Request was not answered with a failure")
+	}
+	var wait *ngapType.TimeToWait
+	for _, ie := range m.pdu.UnsuccessfulOutcome.Value.NGSetup.ProtocolIEs.List {
+		if ie.Value.Present == ngapType.NGSetupFailureIEsPresentTimeToWait {
+			wait = ie.Value.TimeToWait
+		}
+	}
+	if wait == nil || wait.Value != ngapType.TimeToWaitPresentV20s {
+		t.Errorf("time to wait mismatch. got = %v", wait)
+	}
+
+	rejectNoBackend(ran, mustDecodeNgap(t, uplinkNASTransport(t, 5, 6)))
+	m = answer()
+	if !m.initiating(ngapType.InitiatingMessagePresentErrorIndication) {
+		t.Fatalf("uplink NAS transport was not answered with an error indication")
+	}
+	if !m.hasAmfUeNgapId || m.amfUeNgapId != 5 || !m.hasRanUeNgapId || m.ranUeNgapId != 6 {
+		t.Errorf("error indication UE NGAP IDs mismatch. got = %d/%d", m.amfUeNgapId, m.ranUeNgapId)
+	}
+
+	rejectNoBackend(ran, m)
+	rejectNoBackend(ran, nil)
+	select {
+	case m := <-received:
+		t.Errorf("error indication was answered with %v", m.pdu.Present)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -8,19 +8,18 @@ package backend
 import (
 	stdctx "context"
 	"encoding/binary"
+	"fmt"
 	"net"
-	"time"
+	"strconv"
 
 	"github.com/ishidawataru/sctp"
 	"github.com/omec-project/sctplb/context"
 	"github.com/omec-project/sctplb/logger"
 )
 
-var next int
-
 type Backend interface {
-	State() bool
-	Send(msg []byte, b bool, ran *context.Ran) error
+	State() context.NFState
+	Send(msg []byte, b bool, ran *context.Ran, info *sctp.SndRcvInfo) error
 }
 
 // returns the backendNF using RoundRobin algorithm
@@ -32,68 +31,82 @@ func RoundRobin() Backend {
 		logger.DispatchLog.Errorln("there are no backend NFs running")
 		return nil
 	}
-	if next >= length {
-		next = 0
-	}
-
-	instance := ctx.Backends[next]
-	next++
-	return instance
+	return roundRobin.Select(ctx.Backends, nil)
 }
 
-func (b BackendSvc) DispatchAddServer() {
+// DispatchAddServer discovers the backends of every configured service and
+// adds them to the pool. It returns an error for an invalid service, otherwise
+// it does not return: the discovery runs for as long as the SCTP service.
+func (b BackendSvc) DispatchAddServer() error {
 	// add server in pool
 	// create server
 	// create server outstanding message queue
 	// connect to server
 	// there can be more than 1 message outstanding toards same server
-	for {
-		ctx := context.Sctplb_Self()
-		svcList := b.Cfg.Configuration.Services
-		for _, svc := range svcList {
-			for {
-				logger.DiscoveryLog.Debugln("discover Service", svc.Uri)
-				ips, err := net.DefaultResolver.LookupIPAddr(stdctx.Background(), svc.Uri)
-				if err != nil {
-					logger.DiscoveryLog.Warnf("discover Service %s error %+v", svc.Uri, err)
-					time.Sleep(2 * time.Second)
-					continue
-				}
-				for _, ipAddr := range ips {
-					ip := ipAddr.IP
-					logger.DiscoveryLog.Debugln("discover Service %s, ip %s", svc.Uri, ", ip", ip.String())
-					found := false
-					if ipv4 := ip.To4(); ipv4 != nil {
-						for _, instance := range ctx.Backends {
-							b := instance.(*GrpcServer)
-							if b.address == ipv4.String() {
-								found = true
-								break
-							}
-						}
-						if found {
-							continue
-						}
-						logger.DiscoveryLog.Infoln("new server found IPv4:", ipv4.String())
-						var backend context.NF
-						switch b.Cfg.Configuration.Type {
-						case "grpc":
-							backend = &GrpcServer{
-								address: ipv4.String(),
-							}
-						default:
-							logger.DiscoveryLog.Warnln("unsupported backend type:", b.Cfg.Configuration.Type)
-						}
-						ctx.Lock()
-						ctx.AddNF(backend)
-						ctx.Unlock()
-						go backend.ConnectToServer(b.Cfg.Configuration.SctpGrpcPort)
-					}
-				}
-			}
+	var workers []*discoveryWorker
+	for _, svc := range b.Cfg.Configuration.Services {
+		w, err := newDiscoveryWorker(svc, b.Cfg.Configuration.SctpGrpcPort, b.addBackend, removeBackend)
+		if err != nil {
+			return fmt.Errorf("invalid service %s: %w", serviceName(svc), err)
+		}
+		workers = append(workers, w)
+	}
+	for _, w := range workers {
+		go w.run(stdctx.Background())
+	}
+	select {}
+}
+
+// addBackend creates and connects a backend NF for ep unless one already
+// exists, it returns the new backend or nil
+func (b BackendSvc) addBackend(ep Endpoint) context.NF {
+	if ep.IP == nil {
+		return nil
+	}
+	// IPv4-mapped IPv6 addresses are rendered in their IPv4 form, so one
+	// backend has one canonical address whatever way it was discovered
+	address := ep.IP.String()
+	port := ep.Port
+	if port == 0 {
+		port = b.Cfg.Configuration.SctpGrpcPort
+	}
+	ctx := context.Sctplb_Self()
+	ctx.Lock()
+	for _, instance := range ctx.Backends {
+		b := instance.(*GrpcServer)
+		if b.address == address && b.port == port {
+			ctx.Unlock()
+			return nil
 		}
-		time.Sleep(2 * time.Second)
 	}
+	var backend context.NF
+	switch b.Cfg.Configuration.Type {
+	case "grpc":
+		backend = newGrpcServer(address, port, b.Cfg.Configuration)
+	default:
+		ctx.Unlock()
+		logger.DiscoveryLog.Warnln("unsupported backend type:", b.Cfg.Configuration.Type)
+		return nil
+	}
+	backend.(*GrpcServer).weight = ep.Weight
+	logger.DiscoveryLog.Infof("new server found: %s weight: %d", net.JoinHostPort(address, strconv.Itoa(port)), ep.Weight)
+	ctx.AddNF(backend)
+	ctx.Unlock()
+	go backend.ConnectToServer(port)
+	return backend
+}
+
+// removeBackend takes a backend out of the pool so it gets no new messages,
+// then drains and closes it and hands its gNBs over to the remaining backends
+func removeBackend(nf context.NF) {
+	logger.DiscoveryLog.Infof("removing backend %v", nf)
+	deleteBackendNF(nf)
+	go func() {
+		nf.Close()
+		if g, ok := nf.(*GrpcServer); ok {
+			g.handoverRans()
+		}
+	}()
 }
 
 func deleteBackendNF(b context.NF) {
@@ -101,19 +114,18 @@ func deleteBackendNF(b context.NF) {
 	ctx.Lock()
 	defer ctx.Unlock()
 	ctx.DeleteNF(b)
+	ueOwners.releaseBackend(b)
 	for _, b1 := range ctx.Backends {
 		logger.AppLog.Infof("available backend %v", b1)
 	}
 }
 
-func dispatchMessage(conn *sctp.SCTPConn, msg []byte) {
+func dispatchMessage(conn *sctp.SCTPConn, msg []byte, info *sctp.SndRcvInfo) {
 	// add this message for one of the client
 	// select server who can handle this message.. round robin
 	// add message in the server queue
 	// select the server which is connected
 
-	// Implement rate limit per gNb here
-	// implement per site rate limit here
 	var peer *SctpConnections
 	p, ok := connections.Load(conn)
 	if !ok {
@@ -124,45 +136,163 @@ func dispatchMessage(conn *sctp.SCTPConn, msg []byte) {
 	logger.SctpLog.Infoln("handle SCTP message from peer", peer.address)
 
 	ctx := context.Sctplb_Self()
-	ctx.Lock()
-	defer ctx.Unlock()
 	ran, _ := ctx.RanFindByConn(conn)
 	if len(msg) == 0 {
 		logger.SctpLog.Infof("send Gnb connection [%v] close message to all AMF Instances", peer.address)
-		if ctx.Backends != nil && ctx.NFLength() > 0 {
-			var i int
-			for ; i < ctx.NFLength(); i++ {
-				backend := ctx.Backends[i]
-				if backend.State() {
-					if err := backend.Send(msg, true, ran); err != nil {
-						logger.SctpLog.Errorln("can not send", err)
-					}
-				}
-			}
-		} else {
+		backends := connectedBackends()
+		if len(backends) == 0 {
 			logger.SctpLog.Errorln("no AMF Connections")
 		}
-		context.Sctplb_Self().DeleteRan(conn)
+		for _, backend := range backends {
+			if err := backend.Send(msg, true, ran, nil); err != nil {
+				logger.SctpLog.Errorln("can not send", err)
+			}
+		}
+		if ran != nil {
+			ueOwners.releaseRan(ran)
+			setups.release(ran)
+			holds.release(ran)
+			rateLimits.release(ran)
+			sites.release(ran)
+		}
+		ctx.DeleteRan(conn)
 		return
 	}
 	if ran == nil {
-		ran = context.Sctplb_Self().NewRan(conn)
+		ran = ctx.NewRan(conn)
 	}
-	if ctx.NFLength() == 0 {
-		logger.AppLog.Errorln("no backend available")
+	// m is nil when the message can not be decoded, it is still sent on
+	m, err := decodeNgap(msg)
+	if err != nil {
+		ran.Log.Warnf("can not decode NGAP message: %v", err)
+	} else if tais, ok := m.supportedTais(); ok {
+		// remembered to match the filters of broadcasts
+		ran.SetSupportedTais(tais)
+	}
+	if !rateLimits.admit(ran, m) || !sites.admit(ran, m) {
+		return
+	}
+	// the messages of a gNB that has messages held wait behind them, so
+	// the backends get them in the order the gNB sent them
+	if holds.holding(ran) {
+		holdMessage(ran, msg, m, info)
 		return
 	}
-	var i int
-	for ; i < ctx.NFLength(); i++ {
-		// Select the backend NF based on RoundRobin Algorithm
-		backend := RoundRobin()
-		if backend.State() {
-			if err := backend.Send(msg, false, ran); err != nil {
-				logger.SctpLog.Errorln("can not send:", err)
+	if !routeMessage(ran, msg, m, info) {
+		holdMessage(ran, msg, m, info)
+	}
+}
+
+// routeMessage sends an uplink message of ran to its backend, it reports false
+// when no backend is available and the message was not sent. m is the decoded
+// message, nil if it can not be decoded.
+func routeMessage(ran *context.Ran, msg []byte, m *ngapMessage, info *sctp.SndRcvInfo) bool {
+	// every backend learns the gNB from its NG Setup and configuration
+	// updates, the gNB gets one response
+	if m != nil && m.isSetupRequest() && setups.replicate(ran, msg, info, m) {
+		return true
+	}
+	// UE-associated messages go to the AMF holding the UE context, the
+	// scheduler only places InitialUEMessage and non-UE signalling
+	backend, owned := ueBackend(ran, m)
+	if owned && backend == nil {
+		// no other AMF has the context of the UE
+		ran.Log.Warnf("rejecting message of UE %d, its AMF is reconnecting", m.ranUeNgapId)
+		rejectNoBackend(ran, m)
+		return true
+	}
+	if !owned {
+		backend = selectBackend(ran)
+		if backend != nil && m != nil && m.isInitialUEMessage() {
+			if backend = admitInitialUE(backend, ran, m); backend == nil {
+				// the gNB sees sctplb as one AMF, which is overloaded
+				ran.Log.Warnln("rejecting InitialUEMessage, every AMF is overloaded")
+				rejectMessage(ran, m, overloadCause())
+				return true
 			}
-			break
 		}
 	}
+	if backend == nil {
+		logger.AppLog.Errorln("no backend available")
+		return false
+	}
+	send := func(b Backend) error {
+		return b.Send(msg, false, ran, info)
+	}
+	// the messages of a UE are not offered to an AMF without its context
+	if owned {
+		if err := send(backend); err != nil {
+			if h, ok := backend.(healthChecked); ok {
+				h.sendFailed(err, false)
+			}
+			logger.SctpLog.Errorln("can not send:", err)
+		}
+	} else if err := sendWithFailover(backend, ran, send); err != nil {
+		logger.SctpLog.Errorln("can not send:", err)
+	} else if m != nil && m.isInitialUEMessage() {
+		sites.ueSent(ran, m)
+	}
+	if m != nil && m.hasRanUeNgapId && m.isUEContextReleaseComplete() {
+		ueOwners.release(ran, m.ranUeNgapId)
+	}
+	return true
+}
+
+// holdMessage holds a message of ran until a backend is available, a message
+// that can not be held and the held message it evicts are answered locally
+func holdMessage(ran *context.Ran, msg []byte, m *ngapMessage, info *sctp.SndRcvInfo) {
+	evicted, ok := holds.hold(ran, msg, m, info)
+	if !ok {
+		rejectNoBackend(ran, m)
+		return
+	}
+	if evicted != nil {
+		rejectNoBackend(ran, evicted.m)
+	}
+	// a backend may have become ready since the message was routed
+	if len(readyBackends()) > 0 {
+		go holds.replay()
+	}
+}
+
+// selectBackend returns the eligible backend NF chosen by the configured
+// scheduler for a message of ran. Sending to it happens outside of the context
+// lock so a full send queue does not hold up other associations.
+func selectBackend(ran *context.Ran) Backend {
+	backends := eligibleBackends()
+	if len(backends) == 0 {
+		return nil
+	}
+	return scheduler.Select(backends, ran)
+}
+
+// readyBackends returns a snapshot of the ready backend NFs
+func readyBackends() []context.NF {
+	ctx := context.Sctplb_Self()
+	ctx.Lock()
+	defer ctx.Unlock()
+	var backends []context.NF
+	for _, backend := range ctx.Backends {
+		if backend.State() == context.NFReady {
+			backends = append(backends, backend)
+		}
+	}
+	return backends
+}
+
+// connectedBackends returns a snapshot of the backend NFs that take messages,
+// the ready ones and the ones draining
+func connectedBackends() []context.NF {
+	ctx := context.Sctplb_Self()
+	ctx.Lock()
+	defer ctx.Unlock()
+	var backends []context.NF
+	for _, backend := range ctx.Backends {
+		if state := backend.State(); state == context.NFReady || state == context.NFDraining {
+			backends = append(backends, backend)
+		}
+	}
+	return backends
 }
 
 func handleNotification(conn *sctp.SCTPConn, notificationData []byte) {
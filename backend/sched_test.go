@@ -78,11 +78,11 @@ func Test_RoundRobin(t *testing.T) {
 					t.Errorf("RoundRobin() address mismatch. got = %q, want = %q", got.address, tt.want.address)
 				}
 
-				if got.state != tt.want.state {
-					t.Errorf("RoundRobin() state mismatch. got = %v, want = %v", got.state, tt.want.state)
+				if got.State() != tt.want.State() {
+					t.Errorf("RoundRobin() state mismatch. got = %v, want = %v", got.State(), tt.want.State())
 				}
 
-				// For conn, gc, stream - check if they're both nil or both non-nil
+				// For conn, gc - check if they're both nil or both non-nil
 				if (got.conn == nil) != (tt.want.conn == nil) {
 					t.Errorf("RoundRobin() conn nil mismatch. got nil = %v, want nil = %v", got.conn == nil, tt.want.conn == nil)
 				}
@@ -90,10 +90,6 @@ func Test_RoundRobin(t *testing.T) {
 				if (got.gc == nil) != (tt.want.gc == nil) {
 					t.Errorf("RoundRobin() gc nil mismatch. got nil = %v, want nil = %v", got.gc == nil, tt.want.gc == nil)
 				}
-
-				if (got.stream == nil) != (tt.want.stream == nil) {
-					t.Errorf("RoundRobin() stream nil mismatch. got nil = %v, want nil = %v", got.stream == nil, tt.want.stream == nil)
-				}
 			},
 		)
 	}
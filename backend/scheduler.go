@@ -0,0 +1,257 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/omec-project/sctplb/context"
+)
+
+// scheduling policies selectable in the configuration
+const (
+	SchedulerRoundRobin         = "round-robin"
+	SchedulerWeightedRoundRobin = "weighted-round-robin"
+	SchedulerLeastOutstanding   = "least-outstanding"
+	SchedulerConsistentHash     = "consistent-hash"
+	SchedulerLoadAware          = "load-aware"
+)
+
+// Scheduler picks the backend NF a message from ran is sent to. backends only
+// holds the NFs eligible for the message and is never empty.
+type Scheduler interface {
+	Select(backends []context.NF, ran *context.Ran) context.NF
+}
+
+// weighted is implemented by backends with a relative capacity
+type weighted interface {
+	Weight() int
+}
+
+// loaded is implemented by backends that report their load
+type loaded interface {
+	FreeCapacity() float64
+}
+
+// outstanding is implemented by backends that queue messages
+type outstanding interface {
+	Outstanding() int
+}
+
+var (
+	roundRobin           = &roundRobinScheduler{}
+	scheduler  Scheduler = roundRobin
+)
+
+// NewScheduler returns the scheduler of the given policy, "" is round-robin
+func NewScheduler(policy string) (Scheduler, error) {
+	switch policy {
+	case "", SchedulerRoundRobin:
+		return &roundRobinScheduler{}, nil
+	case SchedulerWeightedRoundRobin:
+		return &weightedRoundRobinScheduler{weight: backendWeight}, nil
+	case SchedulerLoadAware:
+		return &weightedRoundRobinScheduler{weight: backendLoadWeight}, nil
+	case SchedulerLeastOutstanding:
+		return &leastOutstandingScheduler{}, nil
+	case SchedulerConsistentHash:
+		return &consistentHashScheduler{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported scheduler: %s", policy)
+	}
+}
+
+// SetScheduler selects the scheduling policy used to dispatch uplink messages,
+// it has to be called before the SCTP service is started
+func SetScheduler(policy string) error {
+	s, err := NewScheduler(policy)
+	if err != nil {
+		return err
+	}
+	scheduler = s
+	return nil
+}
+
+func backendWeight(nf context.NF) int {
+	if w, ok := nf.(weighted); ok && w.Weight() > 0 {
+		return w.Weight()
+	}
+	return 1
+}
+
+// backendLoadWeight scales the weight of a backend by its free capacity, a
+// backend reporting that it is fully loaded gets no new UEs
+func backendLoadWeight(nf context.NF) int {
+	w := backendWeight(nf)
+	if l, ok := nf.(loaded); ok {
+		return int(math.Round(float64(w) * l.FreeCapacity()))
+	}
+	return w * neutralCapacity
+}
+
+func backendOutstanding(nf context.NF) int {
+	if o, ok := nf.(outstanding); ok {
+		return o.Outstanding()
+	}
+	return 0
+}
+
+func backendKey(nf context.NF) string {
+	if b, ok := nf.(*GrpcServer); ok {
+		return net.JoinHostPort(b.address, strconv.Itoa(b.port))
+	}
+	return fmt.Sprintf("%p", nf)
+}
+
+type roundRobinScheduler struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (s *roundRobinScheduler) Select(backends []context.NF, ran *context.Ran) context.NF {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.next >= len(backends) {
+		s.next = 0
+	}
+	instance := backends[s.next]
+	s.next++
+	return instance
+}
+
+// weightedRoundRobinScheduler is the smooth weighted round-robin of nginx: every
+// backend gets a share of the messages proportional to its weight, and picks
+// of the same backend are spread out instead of coming in bursts. When every
+// backend weighs 0 they are picked in turn.
+type weightedRoundRobinScheduler struct {
+	weight  func(context.NF) int
+	mu      sync.Mutex
+	current map[context.NF]int
+}
+
+func (s *weightedRoundRobinScheduler) Select(backends []context.NF, ran *context.Ran) context.NF {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	weights := make([]int, len(backends))
+	total := 0
+	for i, nf := range backends {
+		weights[i] = max(s.weight(nf), 0)
+		total += weights[i]
+	}
+	if total == 0 {
+		for i := range weights {
+			weights[i] = 1
+		}
+		total = len(weights)
+	}
+	current := make(map[context.NF]int, len(backends))
+	var best context.NF
+	for i, nf := range backends {
+		w := weights[i]
+		current[nf] = s.current[nf] + w
+		if w > 0 && (best == nil || current[nf] > current[best]) {
+			best = nf
+		}
+	}
+	current[best] -= total
+	s.current = current
+	return best
+}
+
+// leastOutstandingScheduler picks the backend with the fewest queued messages,
+// ties are broken in round-robin order
+type leastOutstandingScheduler struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (s *leastOutstandingScheduler) Select(backends []context.NF, ran *context.Ran) context.NF {
+	s.mu.Lock()
+	start := s.next % len(backends)
+	s.next++
+	s.mu.Unlock()
+	var best context.NF
+	least := 0
+	for i := range backends {
+		nf := backends[(start+i)%len(backends)]
+		if n := backendOutstanding(nf); best == nil || n < least {
+			best, least = nf, n
+		}
+	}
+	return best
+}
+
+// consistentHashVirtualNodes is the number of points of each backend on the ring
+const consistentHashVirtualNodes = 100
+
+// consistentHashScheduler maps every gNB to a backend through a hash ring, so a
+// gNB keeps its backend and only the gNBs of a backend that joins or leaves are
+// moved. gNBs are hashed on the address of their association, which unlike the
+// GnbId learnt from the NG Setup is known from the first message on.
+type consistentHashScheduler struct {
+	mu     sync.Mutex
+	keys   []string
+	points []uint32
+	owners map[uint32]string
+}
+
+func (s *consistentHashScheduler) Select(backends []context.NF, ran *context.Ran) context.NF {
+	if ran == nil {
+		return backends[0]
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.build(backends)
+	h := hash32(ran.GnbIp)
+	i, _ := slices.BinarySearch(s.points, h)
+	if i == len(s.points) {
+		i = 0
+	}
+	owner := s.owners[s.points[i]]
+	for _, nf := range backends {
+		if backendKey(nf) == owner {
+			return nf
+		}
+	}
+	return backends[0]
+}
+
+// build rebuilds the ring when the set of backends changed
+func (s *consistentHashScheduler) build(backends []context.NF) {
+	keys := make([]string, 0, len(backends))
+	for _, nf := range backends {
+		keys = append(keys, backendKey(nf))
+	}
+	slices.Sort(keys)
+	if slices.Equal(keys, s.keys) {
+		return
+	}
+	s.keys = keys
+	s.points = s.points[:0]
+	s.owners = make(map[uint32]string, len(keys)*consistentHashVirtualNodes)
+	for _, key := range keys {
+		for v := range consistentHashVirtualNodes {
+			p := hash32(key + "#" + strconv.Itoa(v))
+			if _, taken := s.owners[p]; taken {
+				continue
+			}
+			s.owners[p] = key
+			s.points = append(s.points, p)
+		}
+	}
+	slices.Sort(s.points)
+}
+
+func hash32(key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum32()
+}
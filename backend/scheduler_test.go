@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/context"
+	gClient "github.com/omec-project/sctplb/sdcoreAmfServer"
+)
+
+func schedBackends(weights ...int) []context.NF {
+	var backends []context.NF
+	for i, w := range weights {
+		backends = append(backends, &GrpcServer{
+			address: "10.2.0." + strconv.Itoa(i+1),
+			port:    5000,
+			weight:  w,
+			queue:   newSendQueue(&config.SendQueue{Depth: 8}),
+		})
+	}
+	return backends
+}
+
+func Test_NewScheduler(t *testing.T) {
+	for _, policy := range []string{
+		"", SchedulerRoundRobin, SchedulerWeightedRoundRobin, SchedulerLeastOutstanding, SchedulerConsistentHash, SchedulerLoadAware,
+	} {
+		if _, err := NewScheduler(policy); err != nil {
+			t.Errorf("NewScheduler(%q) failed: %v", policy, err)
+		}
+	}
+	if _, err := NewScheduler("random"); err == nil {
+		t.Errorf("NewScheduler(\"random\") expected error")
+	}
+}
+
+func Test_WeightedRoundRobinScheduler(t *testing.T) {
+	backends := schedBackends(3, 1, 0)
+	s := &weightedRoundRobinScheduler{weight: backendWeight}
+	counts := map[context.NF]int{}
+	for range 50 {
+		counts[s.Select(backends, nil)]++
+	}
+	// weights 3:1:1, a zero weight counts as 1
+	want := []int{30, 10, 10}
+	for i, nf := range backends {
+		if counts[nf] != want[i] {
+			t.Errorf("backend %d picks mismatch. got = %d, want = %d", i, counts[nf], want[i])
+		}
+	}
+	// the heavy backend must not be picked in a burst of three
+	first, second := s.Select(backends, nil), s.Select(backends, nil)
+	if first == backends[0] && second == backends[0] && s.Select(backends, nil) == backends[0] {
+		t.Errorf("weighted picks are not spread")
+	}
+}
+
+func Test_LoadAwareScheduler(t *testing.T) {
+	backends := schedBackends(1, 1, 2)
+	backends[0].(*GrpcServer).load.report(&gClient.LoadReport{RelativeCapacity: 200, Load: 50})
+	backends[1].(*GrpcServer).load.report(&gClient.LoadReport{RelativeCapacity: 200, Load: 100})
+	s := &weightedRoundRobinScheduler{weight: backendLoadWeight}
+	counts := map[context.NF]int{}
+	for range 30 {
+		counts[s.Select(backends, nil)]++
+	}
+	// free capacities 100:0:100 with the third backend weighing 2, and a
+	// fully loaded backend gets nothing
+	want := []int{10, 0, 20}
+	for i, nf := range backends {
+		if counts[nf] != want[i] {
+			t.Errorf("backend %d picks mismatch. got = %d, want = %d", i, counts[nf], want[i])
+		}
+	}
+
+	backends = schedBackends(1, 1)
+	for _, nf := range backends {
+		nf.(*GrpcServer).load.report(&gClient.LoadReport{RelativeCapacity: 100, Load: 100})
+	}
+	counts = map[context.NF]int{}
+	for range 4 {
+		counts[s.Select(backends, nil)]++
+	}
+	if counts[backends[0]] != 2 || counts[backends[1]] != 2 {
+		t.Errorf("fully loaded backends must be picked in turn. got = %v", counts)
+	}
+}
+
+func Test_LeastOutstandingScheduler(t *testing.T) {
+	backends := schedBackends(1, 1, 1)
+	for i, n := range []int{3, 1, 2} {
+		for range n {
+			if err := backends[i].(*GrpcServer).queue.push(&gClient.SctplbMessage{}, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	s := &leastOutstandingScheduler{}
+	for range 3 {
+		if got := s.Select(backends, nil); got != backends[1] {
+			t.Errorf("least outstanding mismatch. got = %v, want = %v", got, backends[1])
+		}
+	}
+}
+
+func Test_ConsistentHashScheduler(t *testing.T) {
+	backends := schedBackends(1, 1, 1, 1)
+	s := &consistentHashScheduler{}
+	rans := make([]*context.Ran, 64)
+	owners := make([]context.NF, len(rans))
+	for i := range rans {
+		rans[i] = &context.Ran{GnbIp: "192.168.0." + strconv.Itoa(i) + ":38412"}
+		owners[i] = s.Select(backends, rans[i])
+		// the gNB keeps its backend once its GnbId is learnt
+		rans[i].SetRanId("208:93:" + strconv.Itoa(i))
+		if again := s.Select(backends, rans[i]); again != owners[i] {
+			t.Fatalf("gNB %d moved between picks", i)
+		}
+	}
+
+	// only the gNBs of the removed backend may move
+	removed := backends[1]
+	remaining := []context.NF{backends[0], backends[2], backends[3]}
+	for i, ran := range rans {
+		got := s.Select(remaining, ran)
+		if owners[i] != removed && got != owners[i] {
+			t.Errorf("gNB %d moved from %v to %v", i, owners[i], got)
+		}
+		if got == removed {
+			t.Errorf("gNB %d still mapped to the removed backend", i)
+		}
+	}
+}
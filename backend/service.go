@@ -18,7 +18,7 @@ import (
 )
 
 type SCTPHandler struct {
-	HandleMessage      func(conn *sctp.SCTPConn, msg []byte)
+	HandleMessage      func(conn *sctp.SCTPConn, msg []byte, info *sctp.SndRcvInfo)
 	HandleNotification func(conn *sctp.SCTPConn, notificationData []byte)
 }
 
@@ -173,6 +173,12 @@ func listenAndServe(addr *sctp.SCTPAddr, handler SCTPHandler) {
 		peer := &SctpConnections{}
 		peer.conn = newConn
 		peer.address = newConn.RemoteAddr().String()
+		if status, err := newConn.GetStatus(); err != nil {
+			logger.SctpLog.Warnf("get SCTP status error: %+v, using stream 0 only", err)
+		} else {
+			peer.ostreams = status.Ostreams
+			logger.SctpLog.Debugf("%d outbound streams negotiated", status.Ostreams)
+		}
 		connections.Store(newConn, peer)
 
 		go handleConnection(newConn, readBufSize, handler)
@@ -250,6 +256,6 @@ func handleConnection(conn *sctp.SCTPConn, bufsize uint32, handler SCTPHandler)
 		logger.SctpLog.Debugf("read %d bytes", n)
 		logger.SctpLog.Debugf("packet content: %+v", hex.Dump(buf[:n]))
 
-		handler.HandleMessage(conn, buf[:n])
+		handler.HandleMessage(conn, buf[:n], info)
 	}
 }
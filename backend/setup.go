@@ -0,0 +1,293 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ishidawataru/sctp"
+	"github.com/omec-project/ngap/v2/ngapType"
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/context"
+	"github.com/omec-project/sctplb/logger"
+	gClient "github.com/omec-project/sctplb/sdcoreAmfServer"
+)
+
+// policies combining the answers of the backends to a replicated NG Setup or
+// RAN Configuration Update into the one response of the gNB. The response is
+// the answer of one backend, the IEs of the other answers are not merged.
+const (
+	// SetupPolicyAnySuccess answers with the first success, and with a
+	// failure once every backend failed
+	SetupPolicyAnySuccess = "any-success"
+	// SetupPolicyAllSuccess answers with the first failure, and with a
+	// success once every backend succeeded
+	SetupPolicyAllSuccess = "all-success"
+	// SetupPolicyFirst answers with the first answer
+	SetupPolicyFirst = "first"
+)
+
+const defaultSetupTimeout = 5 * time.Second
+
+// setupAnswer is the answer of a backend to a replicated procedure
+type setupAnswer struct {
+	response *gClient.AmfMessage
+	m        *ngapMessage
+	success  bool
+}
+
+// pendingSetup is a procedure of a gNB replicated to several backends
+type pendingSetup struct {
+	procedure int64
+	// request is answered locally when no backend answers it
+	request *ngapMessage
+	// waiting holds the backends that did not answer yet
+	waiting map[context.NF]struct{}
+	success *setupAnswer
+	failure *setupAnswer
+	// answered is set once the gNB got its response, the answers that
+	// arrive later are dropped
+	answered bool
+	timer    *time.Timer
+}
+
+// setupTable tracks the replicated procedures, a gNB runs one at a time
+type setupTable struct {
+	mu      sync.Mutex
+	policy  string
+	timeout time.Duration
+	pending map[*context.Ran]*pendingSetup
+	// late holds the procedures the gNB got its response to while some
+	// backends did not answer yet, their answers are dropped until the gNB
+	// starts another procedure
+	late map[*context.Ran]*pendingSetup
+}
+
+var setups = &setupTable{
+	policy:  SetupPolicyAnySuccess,
+	timeout: defaultSetupTimeout,
+	pending: make(map[*context.Ran]*pendingSetup),
+	late:    make(map[*context.Ran]*pendingSetup),
+}
+
+// SetSetupReplication configures how the answers to a replicated NG Setup or
+// RAN Configuration Update are combined, it has to be called before the SCTP
+// service is started
+func SetSetupReplication(cfg *config.SetupReplication) error {
+	policy, timeout := SetupPolicyAnySuccess, defaultSetupTimeout
+	if cfg != nil {
+		if cfg.Policy != "" {
+			policy = cfg.Policy
+		}
+		if cfg.Timeout > 0 {
+			timeout = cfg.Timeout
+		}
+	}
+	switch policy {
+	case SetupPolicyAnySuccess, SetupPolicyAllSuccess, SetupPolicyFirst:
+	default:
+		return fmt.Errorf("unsupported setup policy: %s", policy)
+	}
+	setups.mu.Lock()
+	defer setups.mu.Unlock()
+	setups.policy, setups.timeout = policy, timeout
+	return nil
+}
+
+// isSetupRequest reports whether the message is an NG Setup Request or a RAN
+// Configuration Update, which every backend has to know about
+func (m *ngapMessage) isSetupRequest() bool {
+	return m.initiating(ngapType.InitiatingMessagePresentNGSetup) ||
+		m.initiating(ngapType.InitiatingMessagePresentRANConfigurationUpdate)
+}
+
+// setupOutcome returns the procedure code of an answer to an NG Setup Request
+// or a RAN Configuration Update and whether it is a success, ok is false for
+// other messages
+func (m *ngapMessage) setupOutcome() (procedure int64, success, ok bool) {
+	switch m.pdu.Present {
+	case ngapType.NGAPPDUPresentSuccessfulOutcome:
+		outcome := m.pdu.SuccessfulOutcome
+		switch outcome.Value.Present {
+		case ngapType.SuccessfulOutcomePresentNGSetup, ngapType.SuccessfulOutcomePresentRANConfigurationUpdate:
+			return outcome.ProcedureCode.Value, true, true
+		}
+	case ngapType.NGAPPDUPresentUnsuccessfulOutcome:
+		outcome := m.pdu.UnsuccessfulOutcome
+		switch outcome.Value.Present {
+		case ngapType.UnsuccessfulOutcomePresentNGSetup, ngapType.UnsuccessfulOutcomePresentRANConfigurationUpdate:
+			return outcome.ProcedureCode.Value, false, true
+		}
+	}
+	return 0, false, false
+}
+
+// replicate sends the NG Setup or RAN Configuration Update m of ran to every
+// eligible backend. It reports false when there are less than two of them,
+// the message then takes the path of any other message. Either way the
+// answers to the previous procedure of ran are not taken any more.
+func (t *setupTable) replicate(ran *context.Ran, msg []byte, info *sctp.SndRcvInfo, m *ngapMessage) bool {
+	backends := eligibleBackends()
+	t.release(ran)
+	if len(backends) < 2 {
+		return false
+	}
+	p := &pendingSetup{
+		procedure: m.pdu.InitiatingMessage.ProcedureCode.Value,
+		request:   m,
+		waiting:   make(map[context.NF]struct{}, len(backends)),
+	}
+	for _, backend := range backends {
+		p.waiting[backend] = struct{}{}
+	}
+	t.mu.Lock()
+	t.pending[ran] = p
+	p.timer = time.AfterFunc(t.timeout, func() { t.expire(ran, p) })
+	t.mu.Unlock()
+
+	for _, backend := range backends {
+		if err := backend.Send(msg, false, ran, info); err != nil {
+			logger.SctpLog.Errorf("can not replicate setup to %v: %v", backend, err)
+			t.answer(backend, ran, p.procedure, nil)
+		}
+	}
+	return true
+}
+
+// handleAnswer takes the answer m of backend to a replicated procedure of ran,
+// it reports whether m was one. The response of the gNB is sent once the
+// answers decide it.
+func (t *setupTable) handleAnswer(backend context.NF, ran *context.Ran, response *gClient.AmfMessage, m *ngapMessage) bool {
+	if m == nil {
+		return false
+	}
+	procedure, success, ok := m.setupOutcome()
+	if !ok {
+		return false
+	}
+	return t.answer(backend, ran, procedure, &setupAnswer{response: response, m: m, success: success})
+}
+
+// answer records the answer of backend to procedure, nil for a backend that
+// will not answer, and sends the response of the gNB once the policy is
+// decided. The request is answered locally when no backend answers it. It
+// reports false when backend owes no answer to the current procedure of ran.
+func (t *setupTable) answer(backend context.NF, ran *context.Ran, procedure int64, a *setupAnswer) bool {
+	t.mu.Lock()
+	p := t.pending[ran]
+	if p == nil {
+		p = t.late[ran]
+	}
+	if p == nil || p.procedure != procedure {
+		t.mu.Unlock()
+		return false
+	}
+	if _, ok := p.waiting[backend]; !ok {
+		t.mu.Unlock()
+		return false
+	}
+	delete(p.waiting, backend)
+	if p.answered {
+		// the gNB has its response already
+		if len(p.waiting) == 0 {
+			delete(t.late, ran)
+		}
+		t.mu.Unlock()
+		return true
+	}
+	switch {
+	case a == nil:
+	case a.success && p.success == nil:
+		p.success = a
+	case !a.success && p.failure == nil:
+		p.failure = a
+	}
+	send := t.decide(p, a, len(p.waiting) == 0)
+	reject := send == nil && len(p.waiting) == 0
+	if send != nil || reject {
+		p.timer.Stop()
+		t.done(ran, p)
+	}
+	t.mu.Unlock()
+	switch {
+	case send != nil:
+		respond(ran, send)
+	case reject:
+		ran.Log.Warnf("no backend took procedure %d of the gNB", p.procedure)
+		rejectNoBackend(ran, p.request)
+	}
+	return true
+}
+
+// done marks p as answered to the gNB, the backends that did not answer yet
+// are waited for as late answers. It is called with the lock held.
+func (t *setupTable) done(ran *context.Ran, p *pendingSetup) {
+	p.answered = true
+	delete(t.pending, ran)
+	if len(p.waiting) > 0 {
+		t.late[ran] = p
+	}
+}
+
+// decide returns the response of the gNB once the answers received for p
+// decide it under the policy, last is the latest answer and all is set once
+// no other answer is expected
+func (t *setupTable) decide(p *pendingSetup, last *setupAnswer, all bool) *setupAnswer {
+	switch {
+	case t.policy == SetupPolicyFirst && last != nil:
+		return last
+	case t.policy == SetupPolicyAllSuccess && p.failure != nil:
+		return p.failure
+	case t.policy == SetupPolicyAnySuccess && p.success != nil:
+		return p.success
+	case !all:
+		return nil
+	case p.success != nil:
+		return p.success
+	default:
+		return p.failure
+	}
+}
+
+// expire sends the response of the gNB when not every backend answered in
+// time, it is decided by the answers received. Answers that arrive later
+// are dropped.
+func (t *setupTable) expire(ran *context.Ran, p *pendingSetup) {
+	t.mu.Lock()
+	if t.pending[ran] != p {
+		t.mu.Unlock()
+		return
+	}
+	t.done(ran, p)
+	send := t.decide(p, nil, true)
+	t.mu.Unlock()
+	if send == nil {
+		ran.Log.Warnf("no backend answered procedure %d of the gNB", p.procedure)
+		rejectNoBackend(ran, p.request)
+		return
+	}
+	ran.Log.Infof("%d backends did not answer procedure %d in time", len(p.waiting), p.procedure)
+	respond(ran, send)
+}
+
+// release forgets the replicated procedures of a disconnected gNB, or of one
+// that starts another procedure
+func (t *setupTable) release(ran *context.Ran) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p := t.pending[ran]; p != nil {
+		p.timer.Stop()
+		delete(t.pending, ran)
+	}
+	delete(t.late, ran)
+}
+
+func respond(ran *context.Ran, a *setupAnswer) {
+	if err := writeToRan(ran, a.response, a.m); err != nil {
+		ran.Log.Warnf("can not send setup response: %v", err)
+	}
+}
@@ -0,0 +1,275 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/omec-project/ngap/v2/ngapType"
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/context"
+	"github.com/omec-project/sctplb/logger"
+	gClient "github.com/omec-project/sctplb/sdcoreAmfServer"
+)
+
+// ngSetupAnswer encodes an NG Setup Response or, unless success, an NG Setup
+// Failure
+func ngSetupAnswer(t *testing.T, success bool) []byte {
+	if success {
+		return encodeNgap(t, ngapType.NGAPPDU{
+			Present: ngapType.NGAPPDUPresentSuccessfulOutcome,
+			SuccessfulOutcome: &ngapType.SuccessfulOutcome{
+				ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeNGSetup},
+				Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+				Value: ngapType.SuccessfulOutcomeValue{
+					Present: ngapType.SuccessfulOutcomePresentNGSetup,
+					NGSetup: &ngapType.NGSetupResponse{},
+				},
+			},
+		})
+	}
+	return encodeNgap(t, ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentUnsuccessfulOutcome,
+		UnsuccessfulOutcome: &ngapType.UnsuccessfulOutcome{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeNGSetup},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+			Value: ngapType.UnsuccessfulOutcomeValue{
+				Present: ngapType.UnsuccessfulOutcomePresentNGSetup,
+				NGSetup: &ngapType.NGSetupFailure{},
+			},
+		},
+	})
+}
+
+func newSetupTable(policy string, timeout time.Duration) *setupTable {
+	return &setupTable{
+		policy:  policy,
+		timeout: timeout,
+		pending: map[*context.Ran]*pendingSetup{},
+		late:    map[*context.Ran]*pendingSetup{},
+	}
+}
+
+func Test_SetSetupReplication(t *testing.T) {
+	defer func() { _ = SetSetupReplication(nil) }()
+	if err := SetSetupReplication(&config.SetupReplication{Policy: SetupPolicyAllSuccess, Timeout: time.Second}); err != nil {
+		t.Fatalf("SetSetupReplication failed: %v", err)
+	}
+	if setups.policy != SetupPolicyAllSuccess || setups.timeout != time.Second {
+		t.Errorf("setup replication mismatch. got = %s/%v", setups.policy, setups.timeout)
+	}
+	if err := SetSetupReplication(&config.SetupReplication{Policy: "majority"}); err == nil {
+		t.Errorf("SetSetupReplication of an unknown policy expected error")
+	}
+}
+
+func Test_SetupReplication(t *testing.T) {
+	success, failure := ngSetupAnswer(t, true), ngSetupAnswer(t, false)
+	tests := []struct {
+		name    string
+		policy  string
+		answers []bool
+		// after is the number of answers the response is sent after,
+		// 0 when it is sent on timeout
+		after int
+		want  []byte
+	}{
+		{name: "any success", policy: SetupPolicyAnySuccess, answers: []bool{false, true, true}, after: 2, want: success},
+		{name: "any success all failed", policy: SetupPolicyAnySuccess, answers: []bool{false, false, false}, after: 3, want: failure},
+		{name: "all success failed", policy: SetupPolicyAllSuccess, answers: []bool{true, false, true}, after: 2, want: failure},
+		{name: "all success", policy: SetupPolicyAllSuccess, answers: []bool{true, true, true}, after: 3, want: success},
+		{name: "first", policy: SetupPolicyFirst, answers: []bool{false, true, true}, after: 1, want: failure},
+		{name: "timeout", policy: SetupPolicyAllSuccess, answers: []bool{true}, after: 0, want: success},
+	}
+	ctx := context.Sctplb_Self()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var backends []*GrpcServer
+			for _, address := range []string{"10.7.0.1", "10.7.0.2", "10.7.0.3"} {
+				b := readyGrpcServer(address)
+				b.queue = newSendQueue(&config.SendQueue{Depth: 2})
+				ctx.AddNF(b)
+				t.Cleanup(func() { ctx.DeleteNF(b) })
+				backends = append(backends, b)
+			}
+			local, remote := net.Pipe()
+			defer remote.Close()
+			ran := &context.Ran{Conn: local, Log: logger.RanLog}
+			received := make(chan []byte, 4)
+			go func() {
+				buf := make([]byte, 1024)
+				for {
+					n, err := remote.Read(buf)
+					if err != nil {
+						return
+					}
+					received <- bytes.Clone(buf[:n])
+				}
+			}()
+
+			table := newSetupTable(tt.policy, 50*time.Millisecond)
+			request := ngSetupRequest(t, []byte{0, 0, 1})
+			if !table.replicate(ran, request, nil, mustDecodeNgap(t, request)) {
+				t.Fatal("NG Setup was not replicated")
+			}
+			for _, b := range backends {
+				if got := <-b.queue.ch; !bytes.Equal(got.Msg, request) {
+					t.Fatalf("server %v did not get the NG Setup", b.address)
+				}
+			}
+			for i, ok := range tt.answers {
+				msg := ngSetupAnswer(t, ok)
+				response := &gClient.AmfMessage{Msg: msg}
+				if !table.handleAnswer(backends[i], ran, response, mustDecodeNgap(t, msg)) {
+					t.Fatalf("answer %d was not taken", i)
+				}
+				if i+1 < tt.after && len(received) != 0 {
+					t.Fatalf("response sent after %d answers, want %d", i+1, tt.after)
+				}
+			}
+			select {
+			case got := <-received:
+				if !bytes.Equal(got, tt.want) {
+					t.Errorf("response mismatch. got = %x, want = %x", got, tt.want)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("gNB got no response")
+			}
+			select {
+			case got := <-received:
+				t.Errorf("gNB got a second response %x", got)
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func Test_SetupReplicationSingleBackend(t *testing.T) {
+	ctx := context.Sctplb_Self()
+	b := readyGrpcServer("10.7.0.4")
+	ctx.AddNF(b)
+	defer ctx.DeleteNF(b)
+	ran := &context.Ran{}
+	table := newSetupTable(SetupPolicyAnySuccess, time.Second)
+	request := ngSetupRequest(t, []byte{0, 0, 1})
+	if table.replicate(ran, request, nil, mustDecodeNgap(t, request)) {
+		t.Errorf("NG Setup replicated to a single backend")
+	}
+	msg := ngSetupAnswer(t, true)
+	if table.handleAnswer(b, ran, &gClient.AmfMessage{Msg: msg}, mustDecodeNgap(t, msg)) {
+		t.Errorf("answer to a procedure that was not replicated was taken")
+	}
+}
+
+func Test_SetupReplicationSendFailed(t *testing.T) {
+	ctx := context.Sctplb_Self()
+	for _, address := range []string{"10.7.0.5", "10.7.0.6"} {
+		b := readyGrpcServer(address)
+		b.queue = newSendQueue(&config.SendQueue{Depth: 1, Overflow: OverflowReject})
+		if err := b.queue.push(&gClient.SctplbMessage{}, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		ctx.AddNF(b)
+		t.Cleanup(func() { ctx.DeleteNF(b) })
+	}
+	local, remote := net.Pipe()
+	defer remote.Close()
+	ran := &context.Ran{Conn: local, Log: logger.RanLog}
+	received := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 1024)
+		if n, err := remote.Read(buf); err == nil {
+			received <- bytes.Clone(buf[:n])
+		}
+	}()
+
+	// no backend takes the NG Setup, the gNB is answered locally
+	table := newSetupTable(SetupPolicyAnySuccess, time.Hour)
+	request := ngSetupRequest(t, []byte{0, 0, 1})
+	if !table.replicate(ran, request, nil, mustDecodeNgap(t, request)) {
+		t.Fatal("NG Setup was not replicated")
+	}
+	select {
+	case got := <-received:
+		if m := mustDecodeNgap(t, got); m.pdu.Present != ngapType.NGAPPDUPresentUnsuccessfulOutcome {
+			t.Errorf("NG Setup was not answered with a failure")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("gNB got no response")
+	}
+	if len(table.pending) != 0 {
+		t.Errorf("procedure is still pending")
+	}
+}
+
+func Test_SetupReplicationLateAnswer(t *testing.T) {
+	ctx := context.Sctplb_Self()
+	var backends []*GrpcServer
+	for _, address := range []string{"10.7.0.7", "10.7.0.8", "10.7.0.9"} {
+		b := readyGrpcServer(address)
+		b.queue = newSendQueue(&config.SendQueue{Depth: 2})
+		ctx.AddNF(b)
+		t.Cleanup(func() { ctx.DeleteNF(b) })
+		backends = append(backends, b)
+	}
+	local, remote := net.Pipe()
+	defer remote.Close()
+	ran := &context.Ran{Conn: local, Log: logger.RanLog}
+	received := make(chan []byte, 4)
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := remote.Read(buf)
+			if err != nil {
+				return
+			}
+			received <- bytes.Clone(buf[:n])
+		}
+	}()
+
+	table := newSetupTable(SetupPolicyAnySuccess, time.Hour)
+	request := ngSetupRequest(t, []byte{0, 0, 1})
+	if !table.replicate(ran, request, nil, mustDecodeNgap(t, request)) {
+		t.Fatal("NG Setup was not replicated")
+	}
+	success := ngSetupAnswer(t, true)
+	answer := func(b *GrpcServer) bool {
+		return table.handleAnswer(b, ran, &gClient.AmfMessage{Msg: success}, mustDecodeNgap(t, success))
+	}
+	if !answer(backends[0]) {
+		t.Fatal("answer was not taken")
+	}
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("gNB got no response")
+	}
+	if len(table.pending) != 0 {
+		t.Errorf("answered procedure is still pending")
+	}
+	// a late answer to the answered procedure is dropped
+	if !answer(backends[2]) {
+		t.Errorf("late answer was not taken")
+	}
+
+	// the gNB starts over with a single eligible backend, its answer is the
+	// response of the gNB
+	ctx.DeleteNF(backends[1])
+	ctx.DeleteNF(backends[2])
+	if table.replicate(ran, request, nil, mustDecodeNgap(t, request)) {
+		t.Fatal("NG Setup replicated to a single backend")
+	}
+	if answer(backends[0]) || answer(backends[1]) {
+		t.Errorf("answer to a procedure that was not replicated was taken")
+	}
+	select {
+	case got := <-received:
+		t.Errorf("gNB got a second response %x", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,344 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/omec-project/ngap/v2/ngapType"
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/context"
+)
+
+// SiteStats are the counters of a site
+type SiteStats struct {
+	Name string `json:"name"`
+	Gnbs int    `json:"gnbs"`
+	UEs  int    `json:"ues"`
+	// Dropped counts the messages over the message budget, RejectedUEs the
+	// new UEs over the new UE budget or cap and RejectedGnbs the messages of
+	// gNBs over the gNB cap
+	Dropped      uint64 `json:"dropped"`
+	RejectedUEs  uint64 `json:"rejectedUEs"`
+	RejectedGnbs uint64 `json:"rejectedGnbs"`
+}
+
+// siteUE is a UE of a site, known by the RAN UE NGAP ID its gNB gave it
+type siteUE struct {
+	ran         *context.Ran
+	ranUeNgapId int64
+}
+
+// site is a group of gNBs sharing an admission budget
+type site struct {
+	name          string
+	networks      []*net.IPNet
+	gnbIdPrefixes []string
+	plmns         []config.Plmn
+	messages      *tokenBucket
+	newUEs        *tokenBucket
+	maxGnbs       int
+	maxUEs        int
+	gnbs          map[*context.Ran]struct{}
+	// ues is only tracked when maxUEs caps them, a UE is in it once its
+	// InitialUEMessage was sent to a backend
+	ues          map[siteUE]struct{}
+	dropped      uint64
+	rejectedUEs  uint64
+	rejectedGnbs uint64
+}
+
+// siteMember is the site of a gNB
+type siteMember struct {
+	site *site
+	// gnbId and plmns are what the site was chosen with, it is chosen again
+	// once the GnbId or the PLMNs of the gNB are learnt
+	gnbId string
+	plmns bool
+	// admitted is set once the gNB counts against the gNB cap of its site
+	admitted bool
+}
+
+// siteTable applies the admission budgets of the sites to the uplink messages,
+// so the gNBs of one site can not starve the other sites of the AMFs
+type siteTable struct {
+	mu      sync.Mutex
+	sites   []*site
+	members map[*context.Ran]*siteMember
+}
+
+var sites = &siteTable{members: make(map[*context.Ran]*siteMember)}
+
+// SetSites configures the sites, it has to be called before the SCTP service
+// is started
+func SetSites(cfgs []config.Site) error {
+	now := time.Now()
+	var parsed []*site
+	for i, cfg := range cfgs {
+		s := &site{
+			name:          cfg.Name,
+			gnbIdPrefixes: cfg.GnbIdPrefixes,
+			plmns:         cfg.Plmns,
+			messages:      newTokenBucket(cfg.Messages, now),
+			newUEs:        newTokenBucket(cfg.NewUEs, now),
+			maxGnbs:       cfg.MaxGnbs,
+			maxUEs:        cfg.MaxUEs,
+			gnbs:          make(map[*context.Ran]struct{}),
+			ues:           make(map[siteUE]struct{}),
+		}
+		if s.name == "" {
+			s.name = fmt.Sprintf("site-%d", i)
+		}
+		for _, cidr := range cfg.Cidrs {
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return fmt.Errorf("invalid cidr of site %s: %w", s.name, err)
+			}
+			s.networks = append(s.networks, network)
+		}
+		if len(s.networks) == 0 && len(s.gnbIdPrefixes) == 0 && len(s.plmns) == 0 {
+			return fmt.Errorf("site %s matches no gNB", s.name)
+		}
+		parsed = append(parsed, s)
+	}
+	sites.mu.Lock()
+	defer sites.mu.Unlock()
+	sites.sites = parsed
+	sites.members = make(map[*context.Ran]*siteMember)
+	return nil
+}
+
+// SiteStatus returns the counters of every site
+func SiteStatus() []SiteStats {
+	sites.mu.Lock()
+	defer sites.mu.Unlock()
+	stats := make([]SiteStats, 0, len(sites.sites))
+	for _, s := range sites.sites {
+		stats = append(stats, SiteStats{
+			Name:         s.name,
+			Gnbs:         len(s.gnbs),
+			UEs:          len(s.ues),
+			Dropped:      s.dropped,
+			RejectedUEs:  s.rejectedUEs,
+			RejectedGnbs: s.rejectedGnbs,
+		})
+	}
+	return stats
+}
+
+// admit applies the budget of the site of ran to the decoded uplink message m,
+// nil if it can not be decoded, it reports whether the message may be sent
+// on. The messages of a gNB over the gNB cap and new UEs over the budget are
+// answered locally, the other messages over the budget are dropped.
+func (t *siteTable) admit(ran *context.Ran, m *ngapMessage) bool {
+	t.mu.Lock()
+	if len(t.sites) == 0 {
+		t.mu.Unlock()
+		return true
+	}
+	ok, reject := t.admitLocked(ran, m, time.Now())
+	t.mu.Unlock()
+	if reject {
+		rejectMessage(ran, m, overloadCause())
+	}
+	return ok
+}
+
+// admitLocked is admit with the lock held, reject is set when the message is
+// to be answered
+func (t *siteTable) admitLocked(ran *context.Ran, m *ngapMessage, now time.Time) (ok, reject bool) {
+	member := t.member(ran)
+	s := member.site
+	if s == nil {
+		return true, false
+	}
+	if !member.admitted {
+		if s.maxGnbs > 0 && len(s.gnbs) >= s.maxGnbs {
+			s.rejectedGnbs++
+			ran.Log.Warnf("rejecting message, site %s has %d gNBs", s.name, len(s.gnbs))
+			return false, true
+		}
+		s.gnbs[ran] = struct{}{}
+		member.admitted = true
+	}
+	if _, ok := s.messages.reserve(now, 0); !ok {
+		s.dropped++
+		ran.Log.Warnf("dropping message over the budget of site %s", s.name)
+		return false, false
+	}
+	switch {
+	case m == nil:
+	case m.isInitialUEMessage() && m.hasRanUeNgapId:
+		ue := siteUE{ran: ran, ranUeNgapId: m.ranUeNgapId}
+		if _, known := s.ues[ue]; known {
+			break
+		}
+		if s.maxUEs > 0 && len(s.ues) >= s.maxUEs {
+			s.rejectedUEs++
+			ran.Log.Warnf("rejecting InitialUEMessage, site %s has %d UEs", s.name, len(s.ues))
+			return false, true
+		}
+		if _, ok := s.newUEs.reserve(now, 0); !ok {
+			s.rejectedUEs++
+			ran.Log.Warnf("rejecting InitialUEMessage over the budget of site %s", s.name)
+			return false, true
+		}
+	case m.isUEContextReleaseComplete() && m.hasRanUeNgapId:
+		delete(s.ues, siteUE{ran: ran, ranUeNgapId: m.ranUeNgapId})
+	case m.isNGReset():
+		s.reset(ran, m)
+	}
+	return true, false
+}
+
+// ueSent counts the UE of the InitialUEMessage m against the UE cap of the
+// site of ran, it is called once the message was sent to a backend so a
+// rejected UE takes no slot
+func (t *siteTable) ueSent(ran *context.Ran, m *ngapMessage) {
+	if !m.hasRanUeNgapId {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if member := t.members[ran]; member != nil && member.site != nil && member.site.maxUEs > 0 {
+		member.site.ues[siteUE{ran: ran, ranUeNgapId: m.ranUeNgapId}] = struct{}{}
+	}
+}
+
+// downlink releases the UEs of ran an NG Reset of the AMF resets
+func (t *siteTable) downlink(ran *context.Ran, m *ngapMessage) {
+	if m == nil || !m.isNGReset() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if member := t.members[ran]; member != nil && member.site != nil {
+		member.site.reset(ran, m)
+	}
+}
+
+// reset releases the UEs of ran the NG Reset m resets, it is called with the
+// lock held. UEs only known by their AMF UE NGAP ID are left to their UE
+// Context Release.
+func (s *site) reset(ran *context.Ran, m *ngapMessage) {
+	ids, all := m.ngResetUEs()
+	if all {
+		s.leaveUEs(ran)
+		return
+	}
+	for _, id := range ids {
+		delete(s.ues, siteUE{ran: ran, ranUeNgapId: id})
+	}
+}
+
+// leaveUEs takes the UEs of ran out of s, it is called with the lock held
+func (s *site) leaveUEs(ran *context.Ran) {
+	for ue := range s.ues {
+		if ue.ran == ran {
+			delete(s.ues, ue)
+		}
+	}
+}
+
+// isNGReset reports whether the message is an NG Reset
+func (m *ngapMessage) isNGReset() bool {
+	return m.initiating(ngapType.InitiatingMessagePresentNGReset)
+}
+
+// ngResetUEs returns the RAN UE NGAP IDs of the UEs an NG Reset resets, all
+// is set when it resets the whole NG interface
+func (m *ngapMessage) ngResetUEs() (ranUeNgapIds []int64, all bool) {
+	for _, ie := range m.pdu.InitiatingMessage.Value.NGReset.ProtocolIEs.List {
+		resetType := ie.Value.ResetType
+		if ie.Value.Present != ngapType.NGResetIEsPresentResetType || resetType == nil {
+			continue
+		}
+		switch resetType.Present {
+		case ngapType.ResetTypePresentNGInterface:
+			return nil, true
+		case ngapType.ResetTypePresentPartOfNGInterface:
+			if resetType.PartOfNGInterface == nil {
+				continue
+			}
+			for _, item := range resetType.PartOfNGInterface.List {
+				if item.RANUENGAPID != nil {
+					ranUeNgapIds = append(ranUeNgapIds, item.RANUENGAPID.Value)
+				}
+			}
+		}
+	}
+	return ranUeNgapIds, false
+}
+
+// member returns the site of ran, it is called with the lock held
+func (t *siteTable) member(ran *context.Ran) *siteMember {
+	gnbId := ""
+	if ran.RanId != nil {
+		gnbId = *ran.RanId
+	}
+	tais := ran.SupportedTais()
+	member := t.members[ran]
+	if member != nil && member.gnbId == gnbId && member.plmns == (len(tais) > 0) {
+		return member
+	}
+	next := &siteMember{site: t.match(gnbId, ranIPs(ran), tais), gnbId: gnbId, plmns: len(tais) > 0}
+	if member != nil {
+		if member.site == next.site {
+			next.admitted = member.admitted
+		} else {
+			t.leave(ran, member.site)
+		}
+	}
+	t.members[ran] = next
+	return next
+}
+
+// match returns the first site the gNB with gnbId, addresses ips and
+// supported tais is in
+func (t *siteTable) match(gnbId string, ips []net.IP, tais []context.Tai) *site {
+	for _, s := range t.sites {
+		for _, network := range s.networks {
+			if containsAny(network, ips) {
+				return s
+			}
+		}
+		for _, prefix := range s.gnbIdPrefixes {
+			if gnbId != "" && strings.HasPrefix(gnbId, prefix) {
+				return s
+			}
+		}
+		for _, plmn := range s.plmns {
+			for _, tai := range tais {
+				if tai.Mcc == plmn.Mcc && tai.Mnc == plmn.Mnc {
+					return s
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// leave takes ran and its UEs out of s, it is called with the lock held
+func (t *siteTable) leave(ran *context.Ran, s *site) {
+	if s == nil {
+		return
+	}
+	delete(s.gnbs, ran)
+	s.leaveUEs(ran)
+}
+
+// release takes a disconnected gNB out of its site
+func (t *siteTable) release(ran *context.Ran) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if member := t.members[ran]; member != nil {
+		t.leave(ran, member.site)
+		delete(t.members, ran)
+	}
+}
@@ -0,0 +1,220 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/omec-project/ngap/v2/ngapType"
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/context"
+	"github.com/omec-project/sctplb/logger"
+)
+
+// ngReset returns an NG Reset of the UEs with ranUeNgapIds, or of the whole NG
+// interface without any
+func ngReset(t *testing.T, ranUeNgapIds ...int64) []byte {
+	resetType := ngapType.ResetType{Present: ngapType.ResetTypePresentNGInterface, NGInterface: &ngapType.ResetAll{}}
+	if len(ranUeNgapIds) > 0 {
+		list := &ngapType.UEAssociatedLogicalNGConnectionList{}
+		for _, id := range ranUeNgapIds {
+			list.List = append(list.List, ngapType.UEAssociatedLogicalNGConnectionItem{
+				RANUENGAPID: &ngapType.RANUENGAPID{Value: id},
+			})
+		}
+		resetType = ngapType.ResetType{Present: ngapType.ResetTypePresentPartOfNGInterface, PartOfNGInterface: list}
+	}
+	msg := ngapType.NGReset{}
+	msg.ProtocolIEs.List = []ngapType.NGResetIEs{
+		{
+			Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDCause},
+			Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentIgnore},
+			Value: ngapType.NGResetIEsValue{
+				Present: ngapType.NGResetIEsPresentCause,
+				Cause: &ngapType.Cause{
+					Present: ngapType.CausePresentMisc,
+					Misc:    &ngapType.CauseMisc{Value: ngapType.CauseMiscPresentUnspecified},
+				},
+			},
+		},
+		{
+			Id:          ngapType.ProtocolIEID{Value: ngapType.ProtocolIEIDResetType},
+			Criticality: ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+			Value: ngapType.NGResetIEsValue{
+				Present:   ngapType.NGResetIEsPresentResetType,
+				ResetType: &resetType,
+			},
+		},
+	}
+	return encodeNgap(t, ngapType.NGAPPDU{
+		Present: ngapType.NGAPPDUPresentInitiatingMessage,
+		InitiatingMessage: &ngapType.InitiatingMessage{
+			ProcedureCode: ngapType.ProcedureCode{Value: ngapType.ProcedureCodeNGReset},
+			Criticality:   ngapType.Criticality{Value: ngapType.CriticalityPresentReject},
+			Value: ngapType.InitiatingMessageValue{
+				Present: ngapType.InitiatingMessagePresentNGReset,
+				NGReset: &msg,
+			},
+		},
+	})
+}
+
+func Test_SetSites(t *testing.T) {
+	defer func() {
+		if err := SetSites(nil); err != nil {
+			t.Error(err)
+		}
+	}()
+	tests := []struct {
+		name    string
+		cfgs    []config.Site
+		wantErr bool
+	}{
+		{name: "none", cfgs: nil},
+		{name: "cidr", cfgs: []config.Site{{Cidrs: []string{"10.0.0.0/8"}}}},
+		{name: "invalid cidr", cfgs: []config.Site{{Cidrs: []string{"10.0.0.0"}}}, wantErr: true},
+		{name: "no match", cfgs: []config.Site{{Name: "empty"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := SetSites(tt.cfgs); (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_SiteMembership(t *testing.T) {
+	defer SetSites(nil)
+	if err := SetSites([]config.Site{
+		{Name: "east", Cidrs: []string{"10.20.0.0/16"}},
+		{Name: "west", GnbIdPrefixes: []string{"208:93:01"}},
+		{Name: "north", Plmns: []config.Plmn{{Mcc: "001", Mnc: "01"}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sites.mu.Lock()
+	defer sites.mu.Unlock()
+
+	east := &context.Ran{Conn: addrConn{remote: &net.TCPAddr{IP: net.ParseIP("10.20.3.4")}}}
+	if s := sites.member(east).site; s == nil || s.name != "east" {
+		t.Errorf("site of gNB in cidr mismatch. got = %v", s)
+	}
+
+	ran := &context.Ran{}
+	if s := sites.member(ran).site; s != nil {
+		t.Errorf("unknown gNB is in site %s", s.name)
+	}
+	ran.SetSupportedTais([]context.Tai{{Mcc: "001", Mnc: "01", Tac: "000001"}})
+	if s := sites.member(ran).site; s == nil || s.name != "north" {
+		t.Errorf("site of gNB on its PLMN mismatch. got = %v", s)
+	}
+	// the first matching site wins
+	ran.SetRanId("208:93:0102")
+	if s := sites.member(ran).site; s == nil || s.name != "west" {
+		t.Errorf("site of gNB on its GnbId mismatch. got = %v", s)
+	}
+}
+
+func Test_SiteAdmit(t *testing.T) {
+	defer SetSites(nil)
+	if err := SetSites([]config.Site{{
+		Name:          "lab",
+		GnbIdPrefixes: []string{"lab"},
+		Messages:      &config.RateLimit{Rate: 0.001, Burst: 6},
+		NewUEs:        &config.RateLimit{Rate: 0.001, Burst: 2},
+		MaxGnbs:       1,
+		MaxUEs:        2,
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	ran := &context.Ran{Log: logger.RanLog}
+	ran.SetRanId("lab-1")
+	other := &context.Ran{Log: logger.RanLog}
+	other.SetRanId("lab-2")
+	now := time.Now()
+	admit := func(ran *context.Ran, msg []byte) (ok, reject bool) {
+		sites.mu.Lock()
+		defer sites.mu.Unlock()
+		return sites.admitLocked(ran, mustDecodeNgap(t, msg), now)
+	}
+
+	if ok, _ := admit(ran, ngSetupRequest(t, []byte{0, 0, 1})); !ok {
+		t.Fatalf("first gNB of the site was rejected")
+	}
+	if ok, reject := admit(other, ngSetupRequest(t, []byte{0, 0, 2})); ok || !reject {
+		t.Errorf("gNB over the gNB cap was not rejected")
+	}
+
+	// UEs are capped at 2, a UE takes a slot once it was sent to a backend
+	for i := int64(1); i <= 2; i++ {
+		msg := initialUEMessage(t, i)
+		if ok, _ := admit(ran, msg); !ok {
+			t.Fatalf("InitialUEMessage %d was rejected", i)
+		}
+		if s := SiteStatus(); s[0].UEs != int(i)-1 {
+			t.Errorf("UE %d took a slot before it was sent. UEs = %d", i, s[0].UEs)
+		}
+		sites.ueSent(ran, mustDecodeNgap(t, msg))
+	}
+	if ok, reject := admit(ran, initialUEMessage(t, 3)); ok || !reject {
+		t.Errorf("UE over the UE cap was not rejected")
+	}
+	// a released UE makes room, but the new UE budget of 2 is spent
+	if ok, _ := admit(ran, ueContextReleaseComplete(t, 10, 1)); !ok {
+		t.Fatalf("UE Context Release Complete was dropped")
+	}
+	if ok, reject := admit(ran, initialUEMessage(t, 4)); ok || !reject {
+		t.Errorf("UE over the new UE budget was not rejected")
+	}
+	// the 6 messages above spent the message budget
+	if ok, reject := admit(ran, uplinkNASTransport(t, 10, 2)); ok || reject {
+		t.Errorf("message over the message budget was not dropped")
+	}
+
+	got := SiteStatus()
+	want := SiteStats{Name: "lab", Gnbs: 1, UEs: 1, Dropped: 1, RejectedUEs: 2, RejectedGnbs: 1}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("status mismatch. got = %+v, want = %+v", got, want)
+	}
+
+	// a disconnected gNB makes room for another one
+	sites.release(ran)
+	sites.mu.Lock()
+	sites.sites[0].messages = nil
+	sites.mu.Unlock()
+	if ok, _ := admit(other, ngSetupRequest(t, []byte{0, 0, 2})); !ok {
+		t.Errorf("gNB was rejected after the site had room")
+	}
+}
+
+func Test_SiteNGReset(t *testing.T) {
+	defer SetSites(nil)
+	if err := SetSites([]config.Site{{Name: "lab", GnbIdPrefixes: []string{"lab"}, MaxUEs: 10}}); err != nil {
+		t.Fatal(err)
+	}
+	ran := &context.Ran{Log: logger.RanLog}
+	ran.SetRanId("lab-1")
+	for i := int64(1); i <= 3; i++ {
+		m := mustDecodeNgap(t, initialUEMessage(t, i))
+		if !sites.admit(ran, m) {
+			t.Fatalf("InitialUEMessage %d was rejected", i)
+		}
+		sites.ueSent(ran, m)
+	}
+
+	// an NG Reset of the gNB releases the UEs it resets
+	if !sites.admit(ran, mustDecodeNgap(t, ngReset(t, 2))) {
+		t.Fatal("NG Reset was dropped")
+	}
+	if s := SiteStatus(); s[0].UEs != 2 {
+		t.Errorf("UEs after NG Reset of a UE mismatch. got = %d, want = 2", s[0].UEs)
+	}
+	// and one of the AMF of the whole interface every UE of the gNB
+	sites.downlink(ran, mustDecodeNgap(t, ngReset(t)))
+	if s := SiteStatus(); s[0].UEs != 0 {
+		t.Errorf("UEs after NG Reset of the interface mismatch. got = %d, want = 0", s[0].UEs)
+	}
+}
@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/omec-project/sctplb/context"
+	"github.com/omec-project/sctplb/logger"
+)
+
+// BackendStatus is the externally visible state of a backend NF
+type BackendStatus struct {
+	Address string          `json:"address"`
+	Port    int             `json:"port"`
+	State   context.NFState `json:"state"`
+	Drained bool            `json:"drained,omitempty"`
+	Version uint32          `json:"version"`
+	// Capabilities is the bitmap of the capabilities negotiated
+	Capabilities uint64          `json:"capabilities"`
+	Since        time.Time       `json:"since"`
+	Queue        QueueStats      `json:"queue"`
+	Load         *LoadStatus     `json:"load,omitempty"`
+	Overload     *OverloadStatus `json:"overload,omitempty"`
+	Healthy      bool            `json:"healthy"`
+	// SendFailures counts the messages the backend could not take,
+	// Failovers the ones of them offered to another backend
+	SendFailures uint64                 `json:"sendFailures"`
+	Failovers    uint64                 `json:"failovers"`
+	Breaker      BreakerStatus          `json:"breaker"`
+	Transitions  []context.NFTransition `json:"transitions,omitempty"`
+}
+
+// Status returns a snapshot of every backend NF in the pool
+func Status() []BackendStatus {
+	ctx := context.Sctplb_Self()
+	ctx.Lock()
+	backends := append([]context.NF(nil), ctx.Backends...)
+	ctx.Unlock()
+
+	status := make([]BackendStatus, 0, len(backends))
+	for _, instance := range backends {
+		b, ok := instance.(*GrpcServer)
+		if !ok {
+			continue
+		}
+		s := BackendStatus{
+			Address:      b.address,
+			Port:         b.port,
+			State:        b.State(),
+			Drained:      b.Drained(),
+			Version:      b.version.Load(),
+			Capabilities: b.capabilities.Load(),
+			Since:        b.state.Since(),
+			Transitions:  b.Transitions(),
+			Load:         b.LoadStatus(),
+			Overload:     b.OverloadStatus(),
+			Healthy:      b.Healthy(),
+			SendFailures: b.sendFailures.Load(),
+			Failovers:    b.failovers.Load(),
+			Breaker:      b.BreakerStatus(),
+		}
+		if b.queue != nil {
+			s.Queue = b.QueueStats()
+		}
+		status = append(status, s)
+	}
+	return status
+}
+
+// ServeStatus serves the backend status as JSON on http://addr/status, the
+// counters of the hold queues on http://addr/hold, the ones of the rate
+// limiting on http://addr/ratelimit and the ones of the sites on
+// http://addr/sites
+func ServeStatus(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(Status()); err != nil {
+			logger.AppLog.Warnf("encode status: %v", err)
+		}
+	})
+	mux.HandleFunc("/hold", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(HoldStatus()); err != nil {
+			logger.AppLog.Warnf("encode hold status: %v", err)
+		}
+	})
+	mux.HandleFunc("/ratelimit", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(RateLimitStatus()); err != nil {
+			logger.AppLog.Warnf("encode rate limit status: %v", err)
+		}
+	})
+	mux.HandleFunc("/sites", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(SiteStatus()); err != nil {
+			logger.AppLog.Warnf("encode site status: %v", err)
+		}
+	})
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	logger.AppLog.Infof("status endpoint listening on %s", addr)
+	if err := server.ListenAndServe(); err != nil {
+		logger.AppLog.Errorf("status endpoint error: %v", err)
+	}
+}
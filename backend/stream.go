@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"github.com/ishidawataru/sctp"
+	"github.com/omec-project/ngap/v2"
+	"github.com/omec-project/sctplb/context"
+	gClient "github.com/omec-project/sctplb/sdcoreAmfServer"
+)
+
+// writeToRan sends a downlink message of a backend to ran. The message goes
+// out on the SCTP stream, with the PPID and ordering the backend asked for,
+// falling back to the stream chosen by downlinkStream for the decoded message
+// m and the NGAP PPID.
+func writeToRan(ran *context.Ran, response *gClient.AmfMessage, m *ngapMessage) error {
+	conn, ok := ran.Conn.(*sctp.SCTPConn)
+	if !ok {
+		_, err := ran.Conn.Write(response.Msg)
+		return err
+	}
+	info := &sctp.SndRcvInfo{PPID: ngap.PPID}
+	if response.Ppid != 0 {
+		info.PPID = response.Ppid
+	}
+	if response.Unordered {
+		info.Flags = sctp.SCTP_UNORDERED
+	}
+	var ostreams uint16
+	if p, ok := connections.Load(conn); ok {
+		ostreams = p.(*SctpConnections).ostreams
+	}
+	if response.StreamId != nil {
+		info.Stream = uint16(*response.StreamId)
+	} else {
+		info.Stream = downlinkStream(m, ostreams)
+	}
+	if ostreams > 0 && info.Stream >= ostreams {
+		ran.Log.Warnf("stream %d requested by AMF %v is not open, using stream 0", info.Stream, response.AmfId)
+		info.Stream = 0
+	}
+	_, err := conn.SCTPWrite(response.Msg, info)
+	return err
+}
+
+// downlinkStream picks the stream of a downlink message the backend did not
+// pick one for: non-UE signalling uses stream 0 as NGAP requires, the
+// messages of a UE stick to one of the other streams selected by its UE NGAP
+// ID, so they stay in order while different UEs are spread over the streams
+func downlinkStream(m *ngapMessage, ostreams uint16) uint16 {
+	if m == nil || ostreams < 2 {
+		return 0
+	}
+	var id int64
+	switch {
+	case m.hasRanUeNgapId:
+		id = m.ranUeNgapId
+	case m.hasAmfUeNgapId:
+		id = m.amfUeNgapId
+	default:
+		return 0
+	}
+	return 1 + uint16(uint64(id)%uint64(ostreams-1))
+}
@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ishidawataru/sctp"
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/context"
+	gClient "github.com/omec-project/sctplb/sdcoreAmfServer"
+)
+
+func Test_DownlinkStream(t *testing.T) {
+	ue := mustDecodeNgap(t, ueContextReleaseCommand(t, 10, 7))
+	if !ue.hasRanUeNgapId || ue.ranUeNgapId != 7 {
+		t.Fatalf("RAN UE NGAP ID mismatch. got = %d (%v), want = 7", ue.ranUeNgapId, ue.hasRanUeNgapId)
+	}
+	nonUe := mustDecodeNgap(t, ngSetupRequest(t, []byte{0, 0, 1}))
+
+	tests := []struct {
+		name     string
+		m        *ngapMessage
+		ostreams uint16
+		want     uint16
+	}{
+		{name: "undecoded", m: nil, ostreams: 3, want: 0},
+		{name: "non-UE", m: nonUe, ostreams: 3, want: 0},
+		{name: "UE single stream", m: ue, ostreams: 1, want: 0},
+		{name: "UE", m: ue, ostreams: 3, want: 2},
+		{name: "UE many streams", m: ue, ostreams: 10, want: 8},
+	}
+	for _, tt := range tests {
+		if got := downlinkStream(tt.m, tt.ostreams); got != tt.want {
+			t.Errorf("%s: downlinkStream = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_SendCarriesStream(t *testing.T) {
+	b := readyGrpcServer("10.4.0.1")
+	b.queue = newSendQueue(&config.SendQueue{Depth: 2})
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	ran := &context.Ran{Conn: local}
+	ran.SetRanId("gnb-1")
+
+	msg := []byte{1, 2, 3}
+	info := &sctp.SndRcvInfo{Stream: 2, PPID: 60, Flags: sctp.SCTP_UNORDERED}
+	// the stream is only carried to backends that negotiated it
+	if err := b.Send(msg, false, ran, info); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if got := <-b.queue.ch; got.StreamId != 0 || got.Ppid != 0 || got.Unordered {
+		t.Errorf("stream info sent without capability. got = %d/%d/%v", got.StreamId, got.Ppid, got.Unordered)
+	}
+
+	b.capabilities.Store(uint64(gClient.Capability_CAP_STREAM_ID))
+	if err := b.Send(msg, false, ran, info); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	msg[0] = 9
+	got := <-b.queue.ch
+	if got.StreamId != 2 || got.Ppid != 60 || !got.Unordered {
+		t.Errorf("stream info mismatch. got = %d/%d/%v", got.StreamId, got.Ppid, got.Unordered)
+	}
+	if got.Msg[0] != 1 {
+		t.Errorf("queued message shares the read buffer")
+	}
+}
@@ -0,0 +1,161 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/omec-project/sctplb/config"
+	"github.com/omec-project/sctplb/logger"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// transportCredentials returns the credentials of the backend connections,
+// plaintext unless cfg is set
+func transportCredentials(cfg *config.TLS) credentials.TransportCredentials {
+	if cfg == nil {
+		return insecure.NewCredentials()
+	}
+	return credentials.NewTLS(newTLSReloader(*cfg).config())
+}
+
+// fileStamp identifies the version of a file on disk
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func stampOf(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// tlsReloader hands out the CA bundle and client certificate of the backend
+// connections. Every handshake checks whether the files changed on disk and
+// reloads them, so rotated certificates are picked up without a restart. A
+// file that can not be loaded fails the handshake, which is then retried by
+// the reconnect logic of the backend.
+type tlsReloader struct {
+	cfg config.TLS
+
+	mu          sync.Mutex
+	caStamp     fileStamp
+	certStamp   fileStamp
+	keyStamp    fileStamp
+	roots       *x509.CertPool
+	certificate *tls.Certificate
+}
+
+func newTLSReloader(cfg config.TLS) *tlsReloader {
+	r := &tlsReloader{cfg: cfg}
+	if err := r.reload(); err != nil {
+		logger.GrpcLog.Errorf("load TLS files: %v", err)
+	}
+	return r
+}
+
+// config returns the client TLS config. The server certificate is verified
+// in VerifyConnection against the current CA bundle instead of a RootCAs
+// pool fixed at the time the config was built.
+func (r *tlsReloader) config() *tls.Config {
+	return &tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		ServerName:           r.cfg.ServerName,
+		InsecureSkipVerify:   true,
+		VerifyConnection:     r.verifyConnection,
+		GetClientCertificate: r.clientCertificate,
+	}
+}
+
+// reload re-reads the files that changed since they were last loaded
+func (r *tlsReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cfg.CaFile != "" {
+		stamp, err := stampOf(r.cfg.CaFile)
+		if err != nil {
+			return err
+		}
+		if r.roots == nil || stamp != r.caStamp {
+			pem, err := os.ReadFile(r.cfg.CaFile)
+			if err != nil {
+				return err
+			}
+			roots := x509.NewCertPool()
+			if !roots.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("no certificate found in %s", r.cfg.CaFile)
+			}
+			r.roots, r.caStamp = roots, stamp
+			logger.GrpcLog.Infof("loaded CA bundle %s", r.cfg.CaFile)
+		}
+	}
+	if r.cfg.CertFile != "" || r.cfg.KeyFile != "" {
+		certStamp, err := stampOf(r.cfg.CertFile)
+		if err != nil {
+			return err
+		}
+		keyStamp, err := stampOf(r.cfg.KeyFile)
+		if err != nil {
+			return err
+		}
+		if r.certificate == nil || certStamp != r.certStamp || keyStamp != r.keyStamp {
+			certificate, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+			if err != nil {
+				return err
+			}
+			r.certificate, r.certStamp, r.keyStamp = &certificate, certStamp, keyStamp
+			logger.GrpcLog.Infof("loaded client certificate %s", r.cfg.CertFile)
+		}
+	}
+	return nil
+}
+
+func (r *tlsReloader) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.certificate == nil {
+		// no client certificate configured, the server decides whether
+		// it accepts the connection without one
+		return &tls.Certificate{}, nil
+	}
+	return r.certificate, nil
+}
+
+// verifyConnection verifies the server certificate chain and name, the
+// system roots are used when no CA bundle is configured
+func (r *tlsReloader) verifyConnection(cs tls.ConnectionState) error {
+	if err := r.reload(); err != nil {
+		return err
+	}
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("server presented no certificate")
+	}
+	r.mu.Lock()
+	roots := r.roots
+	r.mu.Unlock()
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       cs.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
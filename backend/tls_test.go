@@ -0,0 +1,160 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/omec-project/sctplb/config"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert, serial int64) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) write(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if keyFile == "" {
+		return
+	}
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_TLSReloader(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.TLS{
+		CaFile:   filepath.Join(dir, "ca.pem"),
+		CertFile: filepath.Join(dir, "client.pem"),
+		KeyFile:  filepath.Join(dir, "client.key"),
+	}
+	ca := newTestCert(t, "ca", nil, 1)
+	ca.write(t, cfg.CaFile, "")
+	client := newTestCert(t, "sctplb", ca, 2)
+	client.write(t, cfg.CertFile, cfg.KeyFile)
+	server := newTestCert(t, "amf", ca, 3)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{{Certificate: [][]byte{server.der}, PrivateKey: server.key}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    roots,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	peers := make(chan *x509.Certificate, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			tc := conn.(*tls.Conn)
+			if err := tc.Handshake(); err == nil {
+				peers <- tc.ConnectionState().PeerCertificates[0]
+			}
+			tc.Close()
+		}
+	}()
+
+	r := newTLSReloader(cfg)
+	dial := func() error {
+		conn, err := tls.Dial("tcp", ln.Addr().String(), r.config())
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	if err := dial(); err != nil {
+		t.Fatalf("mutual TLS handshake failed: %v", err)
+	}
+	if got := <-peers; got.SerialNumber.Int64() != 2 {
+		t.Errorf("client certificate mismatch. got serial = %v, want = 2", got.SerialNumber)
+	}
+
+	// rotate the client certificate, the next handshake must present it
+	rotated := newTestCert(t, "sctplb", ca, 4)
+	rotated.write(t, cfg.CertFile, cfg.KeyFile)
+	modTime := time.Now().Add(time.Second)
+	for _, f := range []string{cfg.CertFile, cfg.KeyFile} {
+		if err := os.Chtimes(f, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dial(); err != nil {
+		t.Fatalf("handshake after rotation failed: %v", err)
+	}
+	if got := <-peers; got.SerialNumber.Int64() != 4 {
+		t.Errorf("rotated client certificate mismatch. got serial = %v, want = 4", got.SerialNumber)
+	}
+
+	// a CA bundle that does not hold the server CA fails verification
+	newTestCert(t, "other", nil, 5).write(t, cfg.CaFile, "")
+	if err := os.Chtimes(cfg.CaFile, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := dial(); err == nil {
+		t.Errorf("handshake with an untrusted server expected error")
+	}
+}
@@ -5,6 +5,10 @@
 package backend
 
 import (
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/ishidawataru/sctp"
 	"github.com/omec-project/sctplb/config"
 	"github.com/omec-project/sctplb/context"
@@ -15,6 +19,8 @@ import (
 type SctpConnections struct {
 	conn    *sctp.SCTPConn
 	address string
+	// ostreams is the number of outbound streams of the association
+	ostreams uint16
 }
 
 type BackendSvc struct {
@@ -26,8 +32,47 @@ var _ context.NF = &GrpcServer{}
 
 type GrpcServer struct {
 	address string
-	conn    *grpc.ClientConn
-	gc      gClient.NgapServiceClient
-	state   bool
-	stream  gClient.NgapService_HandleMessageClient
+	port    int
+	weight  int
+	// conn is set under mu once ConnectToServer created it
+	conn *grpc.ClientConn
+	// dialOpts configure the connection, including its credentials
+	dialOpts []grpc.DialOption
+	gc       gClient.NgapServiceClient
+	state    context.NFStateMachine
+	queue    *sendQueue
+	// rans holds the gNBs whose NG Setup went through this backend
+	rans             sync.Map // map[*context.Ran]struct{}
+	reconnect        backoff
+	retryWindow      time.Duration
+	handshakeTimeout time.Duration
+	closing          atomic.Bool
+	// version and capabilities are negotiated in the handshake of a session
+	version      atomic.Uint32
+	capabilities atomic.Uint64
+	// drained is set once a draining backend reported that it is empty or
+	// drainTimeout expired, its UEs are then scheduled elsewhere
+	drained      atomic.Bool
+	drainTimeout time.Duration
+	drainTimer   *time.Timer
+	// load is the load last reported by the backend
+	load backendLoad
+	// overload is set by the NGAP Overload Start of the backend
+	overload overloadControl
+	// unhealthyUntil is when a backend that failed a send is eligible
+	// again, in Unix nanoseconds
+	unhealthyUntil atomic.Int64
+	sendFailures   atomic.Uint64
+	failovers      atomic.Uint64
+	// breaker takes the backend out of scheduling while its sends and
+	// receives fail
+	breaker circuitBreaker
+	// stop interrupts a pending reconnect, done is closed once the
+	// supervisor in ConnectToServer returned
+	stop          chan struct{}
+	done          chan struct{}
+	mu            sync.Mutex
+	cancelSession func()
+	// sessionDone is closed once the current session ended
+	sessionDone <-chan struct{}
 }
@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package backend
+
+import (
+	"sync"
+
+	"github.com/omec-project/sctplb/context"
+)
+
+type ueOwner struct {
+	backend     context.NF
+	amfUeNgapId int64
+}
+
+// ueKey is a UE association as its gNB knows it: the RAN UE NGAP ID is
+// allocated by the gNB and unique on its association, while the AMF UE NGAP
+// ID is only unique within one AMF of the pool
+type ueKey struct {
+	ran         *context.Ran
+	ranUeNgapId int64
+}
+
+// ueOwnerTable maps every UE association to the backend that holds the UE
+// context. It is learned from downlink messages, which carry the RAN UE NGAP
+// ID the gNB allocated along with the AMF UE NGAP ID.
+type ueOwnerTable struct {
+	mu     sync.RWMutex
+	owners map[ueKey]ueOwner
+}
+
+var ueOwners = &ueOwnerTable{owners: make(map[ueKey]ueOwner)}
+
+// learn records backend as the owner of the UE with ranUeNgapId on ran, a UE
+// that moved to another AMF gets the new owner
+func (t *ueOwnerTable) learn(ran *context.Ran, ranUeNgapId, amfUeNgapId int64, backend context.NF) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.owners[ueKey{ran: ran, ranUeNgapId: ranUeNgapId}] = ueOwner{backend: backend, amfUeNgapId: amfUeNgapId}
+}
+
+// owner returns the backend of the UE with ranUeNgapId on ran or nil if not
+// known
+func (t *ueOwnerTable) owner(ran *context.Ran, ranUeNgapId int64) context.NF {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.owners[ueKey{ran: ran, ranUeNgapId: ranUeNgapId}].backend
+}
+
+func (t *ueOwnerTable) release(ran *context.Ran, ranUeNgapId int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.owners, ueKey{ran: ran, ranUeNgapId: ranUeNgapId})
+}
+
+// releaseBackend forgets the UEs owned by a backend leaving the pool
+func (t *ueOwnerTable) releaseBackend(backend context.NF) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, o := range t.owners {
+		if o.backend == backend {
+			delete(t.owners, key)
+		}
+	}
+}
+
+// releaseRan forgets the UEs of a gNB whose association went down
+func (t *ueOwnerTable) releaseRan(ran *context.Ran) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key := range t.owners {
+		if key.ran == ran {
+			delete(t.owners, key)
+		}
+	}
+}
+
+func (t *ueOwnerTable) size() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.owners)
+}
+
+// learnUeOwner records b as the owner of the UE a downlink message m to ran
+// is for
+func (b *GrpcServer) learnUeOwner(ran *context.Ran, m *ngapMessage) {
+	if m != nil && m.hasAmfUeNgapId && m.hasRanUeNgapId {
+		ueOwners.learn(ran, m.ranUeNgapId, m.amfUeNgapId, b)
+	}
+}
+
+// ueBackend returns the backend owning the UE a UE-associated uplink message
+// of ran is for, owned is false when the message is left to the scheduler.
+// InitialUEMessage and non-UE signalling have no owner, and a draining backend
+// keeps its UEs until it is drained. The owner is nil while it reconnects: the
+// UE stays with it until it is removed from the pool.
+func ueBackend(ran *context.Ran, m *ngapMessage) (owner Backend, owned bool) {
+	if m == nil || !m.hasRanUeNgapId || m.isInitialUEMessage() {
+		return nil, false
+	}
+	nf := ueOwners.owner(ran, m.ranUeNgapId)
+	if nf == nil {
+		return nil, false
+	}
+	switch nf.State() {
+	case context.NFReady:
+	case context.NFDraining:
+		if d, ok := nf.(interface{ Drained() bool }); ok && d.Drained() {
+			return nil, false
+		}
+	default:
+		return nil, true
+	}
+	return nf, true
+}
@@ -7,6 +7,7 @@ package config
 import (
 	"errors"
 	"os"
+	"time"
 
 	"github.com/omec-project/sctplb/logger"
 	"go.yaml.in/yaml/v4"
@@ -25,16 +26,238 @@ type Info struct {
 	Description string `yaml:"description,omitempty"`
 }
 
+// Service describes how a set of backend NFs is discovered. Discovery selects
+// the provider: "dns" (default) resolves the A/AAAA records of Uri, "srv"
+// resolves the SRV records of Uri, "static" uses Addresses as-is and "file"
+// reads the backend list from File, re-reading it whenever it changes.
+// Every service is discovered independently every RefreshInterval, failed
+// lookups are retried with an exponential backoff capped at MaxRetryInterval.
+// IpFamily restricts the discovered addresses to "ipv4" or "ipv6", by default
+// both are used. Weight is the relative capacity of each discovered backend
+// used by the weighted-round-robin scheduler, it defaults to 1.
 type Service struct {
-	Uri string `yaml:"uri,omitempty"`
+	Uri              string        `yaml:"uri,omitempty"`
+	Discovery        string        `yaml:"discovery,omitempty"`
+	Addresses        []string      `yaml:"addresses,omitempty"`
+	File             string        `yaml:"file,omitempty"`
+	IpFamily         string        `yaml:"ipFamily,omitempty"`
+	Weight           int           `yaml:"weight,omitempty"`
+	RefreshInterval  time.Duration `yaml:"refreshInterval,omitempty"`
+	MaxRetryInterval time.Duration `yaml:"maxRetryInterval,omitempty"`
 }
 
+// Reconnect controls how a backend whose stream failed is reconnected: the
+// delay starts at InitialInterval and grows by Multiplier up to MaxInterval,
+// with Jitter (0..1) of every delay randomized. A backend that stays
+// unreachable for longer than MaxRetryWindow is removed from the pool.
+type Reconnect struct {
+	InitialInterval time.Duration `yaml:"initialInterval,omitempty"`
+	MaxInterval     time.Duration `yaml:"maxInterval,omitempty"`
+	Multiplier      float64       `yaml:"multiplier,omitempty"`
+	Jitter          float64       `yaml:"jitter,omitempty"`
+	MaxRetryWindow  time.Duration `yaml:"maxRetryWindow,omitempty"`
+}
+
+// SendQueue bounds the outbound queue of every backend. Overflow selects what
+// happens when it is full: "block" (default) waits for room, up to
+// BlockTimeout, 1 second by default, "drop-oldest" discards the oldest queued
+// messages and "reject" fails the new message. The messages still queued when
+// the session of a backend ends are dropped.
+type SendQueue struct {
+	Depth        int           `yaml:"depth,omitempty"`
+	Overflow     string        `yaml:"overflow,omitempty"`
+	BlockTimeout time.Duration `yaml:"blockTimeout,omitempty"`
+}
+
+// TLS secures the backend connections. CaFile is the CA bundle the backend
+// certificates are verified against, the system roots are used when it is
+// empty. CertFile and KeyFile are the client certificate presented for mutual
+// TLS. ServerName overrides the name the backend certificates are verified
+// for, by default the backend address. The files are reloaded when they
+// change on disk, so rotated certificates are used on the next handshake.
+type TLS struct {
+	CaFile     string `yaml:"caFile,omitempty"`
+	CertFile   string `yaml:"certFile,omitempty"`
+	KeyFile    string `yaml:"keyFile,omitempty"`
+	ServerName string `yaml:"serverName,omitempty"`
+}
+
+// Grpc tunes the backend connections. KeepaliveTime enables client keepalive
+// pings on an idle transport, a ping that is not answered within
+// KeepaliveTimeout closes the connection; the keepalive enforcement policy of
+// the backends has to allow pings that frequent. PermitWithoutStream also
+// pings while no stream is open. InitialWindowSize and InitialConnWindowSize
+// are the HTTP/2 flow control windows of a stream and of the connection,
+// MaxRecvMsgSize and MaxSendMsgSize bound the size of a message and
+// ConnectTimeout bounds a single connection attempt. Unset values keep the
+// gRPC defaults.
+type Grpc struct {
+	KeepaliveTime         time.Duration `yaml:"keepaliveTime,omitempty"`
+	KeepaliveTimeout      time.Duration `yaml:"keepaliveTimeout,omitempty"`
+	PermitWithoutStream   bool          `yaml:"permitWithoutStream,omitempty"`
+	InitialWindowSize     int32         `yaml:"initialWindowSize,omitempty"`
+	InitialConnWindowSize int32         `yaml:"initialConnWindowSize,omitempty"`
+	MaxRecvMsgSize        int           `yaml:"maxRecvMsgSize,omitempty"`
+	MaxSendMsgSize        int           `yaml:"maxSendMsgSize,omitempty"`
+	ConnectTimeout        time.Duration `yaml:"connectTimeout,omitempty"`
+}
+
+// SetupReplication configures how the answers of the backends to the NG Setup
+// and RAN Configuration Update that sctplb sends to all of them are combined
+// into the one response of the gNB. Policy is "any-success" (default),
+// "all-success" or "first". Once Timeout expires, 5 seconds by default, the
+// response is decided on the answers received. The gNB gets the answer of one
+// backend as-is: the AMF Name, served GUAMIs and other IEs of the other
+// answers are dropped, so the backends are expected to serve the same GUAMIs,
+// and under "any-success" the failure of a mismatched backend goes unnoticed.
+type SetupReplication struct {
+	Policy  string        `yaml:"policy,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// NoBackend configures how sctplb answers a gNB while no backend is
+// available. TimeToWait is sent in the NG Setup Failure, rounded up to a
+// value NGAP can carry, it defaults to 10 seconds.
+type NoBackend struct {
+	TimeToWait time.Duration `yaml:"timeToWait,omitempty"`
+}
+
+// Hold configures the queues holding the uplink messages of each gNB while no
+// backend is ready, they are replayed in order once one is. The queues are
+// disabled unless Depth, the number of messages held per gNB, is set. Messages
+// held for longer than MaxAge, 10 seconds by default, expire. When the queue of
+// a gNB is full its oldest message is answered to make room for the new one.
+type Hold struct {
+	Depth  int           `yaml:"depth,omitempty"`
+	MaxAge time.Duration `yaml:"maxAge,omitempty"`
+}
+
+// Failover configures how a message a backend failed to take is offered to
+// the other backends. Attempts bounds the backends it is offered to, 3 by
+// default, and a backend that failed is passed over by the scheduler for
+// UnhealthyFor, 5 seconds by default. The messages of a UE are never offered
+// to another backend than the one holding its context.
+type Failover struct {
+	Attempts     int           `yaml:"attempts,omitempty"`
+	UnhealthyFor time.Duration `yaml:"unhealthyFor,omitempty"`
+}
+
+// CircuitBreaker configures the circuit breaker of each backend, which takes
+// it out of scheduling while its sends and receives fail. It opens after
+// ConsecutiveFailures failures in a row, 5 by default, or when ErrorRate, 0.5
+// by default, of at least MinRequests results, 20 by default, failed within
+// Window, 10 seconds by default. After OpenFor, 30 seconds by default, it is
+// half-open and closes again after HalfOpenSuccesses successes, 3 by default.
+type CircuitBreaker struct {
+	Window              time.Duration `yaml:"window,omitempty"`
+	ErrorRate           float64       `yaml:"errorRate,omitempty"`
+	MinRequests         int           `yaml:"minRequests,omitempty"`
+	ConsecutiveFailures int           `yaml:"consecutiveFailures,omitempty"`
+	OpenFor             time.Duration `yaml:"openFor,omitempty"`
+	HalfOpenSuccesses   int           `yaml:"halfOpenSuccesses,omitempty"`
+}
+
+// RateLimit is a token bucket admitting Rate messages per second on average
+// and bursts of up to Burst messages, at least 1. A zero Rate does not limit.
+type RateLimit struct {
+	Rate  float64 `yaml:"rate,omitempty"`
+	Burst int     `yaml:"burst,omitempty"`
+}
+
+// GnbRateLimit overrides the rate limits of the gNBs with GnbId or whose
+// address is in the Cidr, the limits it does not set are the global ones
+type GnbRateLimit struct {
+	GnbId      string     `yaml:"gnbId,omitempty"`
+	Cidr       string     `yaml:"cidr,omitempty"`
+	Signalling *RateLimit `yaml:"signalling,omitempty"`
+	InitialUE  *RateLimit `yaml:"initialUE,omitempty"`
+}
+
+// RateLimiting configures the rate limits of the uplink messages of each gNB.
+// Signalling limits the non-UE-associated messages, InitialUE the
+// InitialUEMessages, the other UE-associated messages are not limited. The
+// first entry of Gnbs matching a gNB overrides the limits. Action is what
+// happens to a message over the limit: "drop" (default), "delay" to wait up
+// to MaxDelay, 1 second by default, for the bucket to refill or "reject" to
+// answer it locally with cause control processing overload.
+type RateLimiting struct {
+	Action     string         `yaml:"action,omitempty"`
+	MaxDelay   time.Duration  `yaml:"maxDelay,omitempty"`
+	Signalling *RateLimit     `yaml:"signalling,omitempty"`
+	InitialUE  *RateLimit     `yaml:"initialUE,omitempty"`
+	Gnbs       []GnbRateLimit `yaml:"gnbs,omitempty"`
+}
+
+// Plmn is a PLMN identity, Mnc has two or three digits
+type Plmn struct {
+	Mcc string `yaml:"mcc,omitempty"`
+	Mnc string `yaml:"mnc,omitempty"`
+}
+
+// Site groups the gNBs whose address is in one of Cidrs, whose GnbId starts
+// with one of GnbIdPrefixes or that support one of Plmns, a gNB is in the
+// first site it matches. The gNBs of a site share the budget of the site:
+// Messages limits their uplink messages and NewUEs their InitialUEMessages.
+// MaxGnbs caps the gNBs and MaxUEs the UEs the site has at a time, a zero
+// cap does not limit. A UE counts once its InitialUEMessage was sent to a
+// backend, until its UE Context Release Complete, an NG Reset of its UE or
+// the disconnection of its gNB. A gNB that is only matched on its PLMNs joins
+// its site once its NG Setup was seen.
+type Site struct {
+	Name          string     `yaml:"name,omitempty"`
+	Cidrs         []string   `yaml:"cidrs,omitempty"`
+	GnbIdPrefixes []string   `yaml:"gnbIdPrefixes,omitempty"`
+	Plmns         []Plmn     `yaml:"plmns,omitempty"`
+	Messages      *RateLimit `yaml:"messages,omitempty"`
+	NewUEs        *RateLimit `yaml:"newUEs,omitempty"`
+	MaxGnbs       int        `yaml:"maxGnbs,omitempty"`
+	MaxUEs        int        `yaml:"maxUEs,omitempty"`
+}
+
+// Configuration is the sctplb configuration
 type Configuration struct {
 	Type         string    `yaml:"type,omitempty" valid:"required,in(grpc)"`
 	Services     []Service `yaml:"services,omitempty"`
 	NgapIpList   []string  `yaml:"ngapIpList,omitempty"`
 	NgapPort     int       `yaml:"ngappPort,omitempty"`
 	SctpGrpcPort int       `yaml:"sctpGrpcPort,omitempty"`
+	// Scheduler selects how uplink messages are spread over the backends:
+	// "round-robin" (default), "weighted-round-robin", "least-outstanding",
+	// "consistent-hash" on the gNB or "load-aware" on the load the backends
+	// report
+	Scheduler string     `yaml:"scheduler,omitempty"`
+	Reconnect *Reconnect `yaml:"reconnect,omitempty"`
+	SendQueue *SendQueue `yaml:"sendQueue,omitempty"`
+	// StatusAddr is the host:port of the HTTP status endpoint, it is
+	// disabled when empty
+	StatusAddr string `yaml:"statusAddr,omitempty"`
+	// TLS secures the backend connections, they are plaintext without it
+	TLS  *TLS  `yaml:"tls,omitempty"`
+	Grpc *Grpc `yaml:"grpc,omitempty"`
+	// DrainTimeout bounds how long a draining backend that does not report
+	// that it is empty keeps the messages of its UEs, 5 minutes by default
+	DrainTimeout time.Duration `yaml:"drainTimeout,omitempty"`
+	// HandshakeTimeout bounds how long a new backend may take to answer the
+	// handshake, 5 seconds by default
+	HandshakeTimeout time.Duration `yaml:"handshakeTimeout,omitempty"`
+	// LoadReportTTL is how long a load report takes to decay to the neutral
+	// capacity of a backend that does not report, 30 seconds by default
+	LoadReportTTL time.Duration `yaml:"loadReportTTL,omitempty"`
+	// SetupReplication combines the answers to the NG Setups sent to every
+	// backend
+	SetupReplication *SetupReplication `yaml:"setupReplication,omitempty"`
+	// NoBackend configures the answers to a gNB while no backend is available
+	NoBackend *NoBackend `yaml:"noBackend,omitempty"`
+	// Hold holds the messages of the gNBs while no backend is ready
+	Hold *Hold `yaml:"hold,omitempty"`
+	// Failover offers a message a backend failed to take to the others
+	Failover *Failover `yaml:"failover,omitempty"`
+	// CircuitBreaker takes failing backends out of scheduling
+	CircuitBreaker *CircuitBreaker `yaml:"circuitBreaker,omitempty"`
+	// RateLimiting limits the uplink messages of each gNB
+	RateLimiting *RateLimiting `yaml:"rateLimiting,omitempty"`
+	// Sites share admission budgets among groups of gNBs
+	Sites []Site `yaml:"sites,omitempty"`
 }
 
 func InitConfigFactory(f string) (Config, error) {
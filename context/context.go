@@ -12,6 +12,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/ishidawataru/sctp"
 	"github.com/omec-project/sctplb/logger"
 	"go.uber.org/zap"
 )
@@ -21,6 +22,7 @@ var sctplbContext = SctplbContext{}
 type SctplbContext struct {
 	RanPool  sync.Map // map[net.Conn]*Ran
 	Backends []NF
+	nfHooks  nfStateHooks
 }
 
 var (
@@ -36,6 +38,16 @@ type Ran struct {
 	Conn net.Conn `json:"-"`
 
 	Log *zap.SugaredLogger `json:"-"`
+
+	mu   sync.Mutex
+	tais []Tai
+}
+
+// Tai is a tracking area of a RAN, Tac is hex encoded
+type Tai struct {
+	Mcc string
+	Mnc string
+	Tac string
 }
 
 func (ran *Ran) Remove() {
@@ -47,6 +59,20 @@ func (ran *Ran) SetRanId(gnbId string) {
 	ran.RanId = &gnbId
 }
 
+// SetSupportedTais records the tracking areas the RAN announced in its NG
+// Setup or RAN Configuration Update
+func (ran *Ran) SetSupportedTais(tais []Tai) {
+	ran.mu.Lock()
+	defer ran.mu.Unlock()
+	ran.tais = tais
+}
+
+func (ran *Ran) SupportedTais() []Tai {
+	ran.mu.Lock()
+	defer ran.mu.Unlock()
+	return ran.tais
+}
+
 func (ran *Ran) RanID() string {
 	if ran.RanId != nil {
 		var builder strings.Builder
@@ -112,8 +138,10 @@ func Sctplb_Self() *SctplbContext {
 
 type NF interface {
 	ConnectToServer(int)
-	Send([]byte, bool, *Ran) error
-	State() bool
+	Send([]byte, bool, *Ran, *sctp.SndRcvInfo) error
+	State() NFState
+	Transitions() []NFTransition
+	Close()
 }
 
 func (context *SctplbContext) DeleteNF(target NF) {
@@ -127,6 +155,16 @@ func (context *SctplbContext) DeleteNF(target NF) {
 	}
 }
 
+// HasNF reports whether target is still part of the backend pool
+func (context *SctplbContext) HasNF(target NF) bool {
+	for _, instance := range context.Backends {
+		if instance == target {
+			return true
+		}
+	}
+	return false
+}
+
 func (context *SctplbContext) Iterate(handler func(k int, v NF)) {
 	for k, v := range context.Backends {
 		handler(k, v)
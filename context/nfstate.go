@@ -0,0 +1,176 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package context
+
+import (
+	"sync"
+	"time"
+
+	"github.com/omec-project/sctplb/logger"
+)
+
+// NFState is the connection state of a backend NF
+type NFState int32
+
+const (
+	NFIdle NFState = iota
+	NFConnecting
+	NFHandshaking
+	NFReady
+	NFDraining
+	NFFailed
+	NFClosed
+)
+
+func (s NFState) String() string {
+	switch s {
+	case NFIdle:
+		return "Idle"
+	case NFConnecting:
+		return "Connecting"
+	case NFHandshaking:
+		return "Handshaking"
+	case NFReady:
+		return "Ready"
+	case NFDraining:
+		return "Draining"
+	case NFFailed:
+		return "Failed"
+	case NFClosed:
+		return "Closed"
+	default:
+		return "Unknown"
+	}
+}
+
+func (s NFState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// nfTransitions lists the states each state may move to
+var nfTransitions = map[NFState][]NFState{
+	NFIdle:        {NFConnecting, NFClosed},
+	NFConnecting:  {NFHandshaking, NFFailed, NFClosed},
+	NFHandshaking: {NFReady, NFFailed, NFClosed},
+	NFReady:       {NFDraining, NFFailed, NFClosed},
+	NFDraining:    {NFReady, NFFailed, NFClosed},
+	NFFailed:      {NFConnecting, NFClosed},
+	NFClosed:      {},
+}
+
+// maxNFTransitions is the number of transitions kept per backend
+const maxNFTransitions = 32
+
+type NFTransition struct {
+	From   NFState
+	To     NFState
+	At     time.Time
+	Reason string
+}
+
+// NFStateHook is called after a backend changed its state. Hooks run on the
+// goroutine driving the backend and must not block.
+type NFStateHook func(nf NF, transition NFTransition)
+
+// NFStateMachine tracks the state of a backend NF. The zero value is a machine
+// in the Idle state.
+type NFStateMachine struct {
+	mu          sync.Mutex
+	state       NFState
+	since       time.Time
+	transitions []NFTransition
+}
+
+func (m *NFStateMachine) State() NFState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// Since returns when the current state was entered
+func (m *NFStateMachine) Since() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.since
+}
+
+// Transitions returns the most recent transitions, oldest first
+func (m *NFStateMachine) Transitions() []NFTransition {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]NFTransition(nil), m.transitions...)
+}
+
+// Transition moves nf to state to and notifies the subscribed hooks. It
+// returns false, leaving the state untouched, if the move is not allowed from
+// the current state.
+func (m *NFStateMachine) Transition(nf NF, to NFState, reason string) bool {
+	m.mu.Lock()
+	from := m.state
+	if !nfTransitionAllowed(from, to) {
+		m.mu.Unlock()
+		logger.AppLog.Warnf("invalid backend state transition %v -> %v (%s)", from, to, reason)
+		return false
+	}
+	t := NFTransition{From: from, To: to, At: time.Now(), Reason: reason}
+	m.state = to
+	m.since = t.At
+	if len(m.transitions) == maxNFTransitions {
+		m.transitions = append(m.transitions[:0], m.transitions[1:]...)
+	}
+	m.transitions = append(m.transitions, t)
+	m.mu.Unlock()
+
+	logger.AppLog.Debugf("backend state %v -> %v (%s)", from, to, reason)
+	sctplbContext.notifyNFState(nf, t)
+	return true
+}
+
+func nfTransitionAllowed(from, to NFState) bool {
+	for _, s := range nfTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+type nfStateHooks struct {
+	mu    sync.Mutex
+	next  int
+	hooks map[int]NFStateHook
+}
+
+// SubscribeNFState registers hook for the state changes of every backend, the
+// returned function removes it again
+func (context *SctplbContext) SubscribeNFState(hook NFStateHook) func() {
+	h := &context.nfHooks
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.hooks == nil {
+		h.hooks = make(map[int]NFStateHook)
+	}
+	id := h.next
+	h.next++
+	h.hooks[id] = hook
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.hooks, id)
+	}
+}
+
+func (context *SctplbContext) notifyNFState(nf NF, t NFTransition) {
+	h := &context.nfHooks
+	h.mu.Lock()
+	hooks := make([]NFStateHook, 0, len(h.hooks))
+	for _, hook := range h.hooks {
+		hooks = append(hooks, hook)
+	}
+	h.mu.Unlock()
+	for _, hook := range hooks {
+		hook(nf, t)
+	}
+}
@@ -0,0 +1,62 @@
+// SPDX-FileCopyrightText: 2024 Intel Corporation
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package context
+
+import "testing"
+
+func Test_NFStateMachine(t *testing.T) {
+	var m NFStateMachine
+	if m.State() != NFIdle {
+		t.Fatalf("initial state mismatch. got = %v, want = %v", m.State(), NFIdle)
+	}
+
+	var seen []NFTransition
+	unsubscribe := Sctplb_Self().SubscribeNFState(func(nf NF, transition NFTransition) {
+		seen = append(seen, transition)
+	})
+
+	for _, to := range []NFState{NFConnecting, NFHandshaking, NFReady, NFDraining, NFReady} {
+		if !m.Transition(nil, to, "test") {
+			t.Fatalf("transition to %v rejected", to)
+		}
+	}
+	if m.Transition(nil, NFHandshaking, "test") {
+		t.Errorf("transition Ready -> Handshaking must be rejected")
+	}
+	if m.State() != NFReady {
+		t.Errorf("state mismatch after rejected transition. got = %v, want = %v", m.State(), NFReady)
+	}
+	if len(seen) != 5 || seen[4].From != NFDraining || seen[4].To != NFReady {
+		t.Errorf("hook transitions mismatch. got = %+v", seen)
+	}
+	if m.Since() != seen[4].At {
+		t.Errorf("state timestamp mismatch. got = %v, want = %v", m.Since(), seen[4].At)
+	}
+
+	unsubscribe()
+	m.Transition(nil, NFClosed, "test")
+	if len(seen) != 5 {
+		t.Errorf("hook called after unsubscribe")
+	}
+	if m.Transition(nil, NFConnecting, "test") {
+		t.Errorf("Closed must be final")
+	}
+}
+
+func Test_NFStateMachineTransitionLog(t *testing.T) {
+	var m NFStateMachine
+	m.Transition(nil, NFConnecting, "first")
+	for range maxNFTransitions {
+		m.Transition(nil, NFFailed, "fail")
+		m.Transition(nil, NFConnecting, "retry")
+	}
+	transitions := m.Transitions()
+	if len(transitions) != maxNFTransitions {
+		t.Fatalf("transition log length mismatch. got = %d, want = %d", len(transitions), maxNFTransitions)
+	}
+	if last := transitions[len(transitions)-1]; last.To != NFConnecting || last.Reason != "retry" {
+		t.Errorf("last transition mismatch. got = %+v", last)
+	}
+}
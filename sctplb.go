@@ -49,14 +49,42 @@ func action(ctx context.Context, c *cli.Command) error {
 		return err
 	}
 
+	if err := backend.SetScheduler(sctplbConfig.Configuration.Scheduler); err != nil {
+		logger.AppLog.Errorf("failed to initialize scheduler: %v", err)
+		return err
+	}
+
+	if err := backend.SetSetupReplication(sctplbConfig.Configuration.SetupReplication); err != nil {
+		logger.AppLog.Errorf("failed to initialize setup replication: %v", err)
+		return err
+	}
+	backend.SetNoBackend(sctplbConfig.Configuration.NoBackend)
+	backend.SetHold(sctplbConfig.Configuration.Hold)
+	backend.SetFailover(sctplbConfig.Configuration.Failover)
+	backend.SetCircuitBreaker(sctplbConfig.Configuration.CircuitBreaker)
+	if err := backend.SetRateLimiting(sctplbConfig.Configuration.RateLimiting); err != nil {
+		logger.AppLog.Errorf("failed to initialize rate limiting: %v", err)
+		return err
+	}
+	if err := backend.SetSites(sctplbConfig.Configuration.Sites); err != nil {
+		logger.AppLog.Errorf("failed to initialize sites: %v", err)
+		return err
+	}
+
 	// Read messages from SCTP Sockets and push it on channel
 	logger.AppLog.Infof("sctp port: %d grpc port: %d", sctplbConfig.Configuration.NgapPort, sctplbConfig.Configuration.SctpGrpcPort)
 	backend.ServiceRun(sctplbConfig.Configuration.NgapIpList, sctplbConfig.Configuration.NgapPort)
 
+	if addr := sctplbConfig.Configuration.StatusAddr; addr != "" {
+		go backend.ServeStatus(addr)
+	}
+
 	b := backend.BackendSvc{
 		Cfg: sctplbConfig,
 	}
-	b.DispatchAddServer()
-
+	if err := b.DispatchAddServer(); err != nil {
+		logger.AppLog.Errorf("failed to initialize discovery: %v", err)
+		return err
+	}
 	return nil
 }
@@ -35,27 +35,43 @@ const (
 	MsgType_REDIRECT_MSG MsgType = 4
 	MsgType_GNB_DISC     MsgType = 5
 	MsgType_GNB_CONN     MsgType = 6
+	// sent by an AMF that should get no new UEs, e.g. before an upgrade
+	MsgType_DRAIN MsgType = 7
+	// sent by a draining AMF that takes new UEs again
+	MsgType_UNDRAIN MsgType = 8
+	// sent by a draining AMF once it holds no UE context anymore
+	MsgType_DRAIN_COMPLETE MsgType = 9
+	// sent periodically by an AMF to report its capacity and load
+	MsgType_LOAD_REPORT MsgType = 10
 )
 
 // Enum value maps for MsgType.
 var (
 	MsgType_name = map[int32]string{
-		0: "UNKNOWN",
-		1: "INIT_MSG",
-		2: "GNB_MSG",
-		3: "AMF_MSG",
-		4: "REDIRECT_MSG",
-		5: "GNB_DISC",
-		6: "GNB_CONN",
+		0:  "UNKNOWN",
+		1:  "INIT_MSG",
+		2:  "GNB_MSG",
+		3:  "AMF_MSG",
+		4:  "REDIRECT_MSG",
+		5:  "GNB_DISC",
+		6:  "GNB_CONN",
+		7:  "DRAIN",
+		8:  "UNDRAIN",
+		9:  "DRAIN_COMPLETE",
+		10: "LOAD_REPORT",
 	}
 	MsgType_value = map[string]int32{
-		"UNKNOWN":      0,
-		"INIT_MSG":     1,
-		"GNB_MSG":      2,
-		"AMF_MSG":      3,
-		"REDIRECT_MSG": 4,
-		"GNB_DISC":     5,
-		"GNB_CONN":     6,
+		"UNKNOWN":        0,
+		"INIT_MSG":       1,
+		"GNB_MSG":        2,
+		"AMF_MSG":        3,
+		"REDIRECT_MSG":   4,
+		"GNB_DISC":       5,
+		"GNB_CONN":       6,
+		"DRAIN":          7,
+		"UNDRAIN":        8,
+		"DRAIN_COMPLETE": 9,
+		"LOAD_REPORT":    10,
 	}
 )
 
@@ -86,6 +102,418 @@ func (MsgType) EnumDescriptor() ([]byte, []int) {
 	return file_client_proto_rawDescGZIP(), []int{0}
 }
 
+// optional behaviours of the protocol, a capability bitmap is the sum of the
+// capabilities supported
+type Capability int32
+
+const (
+	Capability_CAP_NONE Capability = 0
+	// StreamId, Ppid and Unordered are carried
+	Capability_CAP_STREAM_ID Capability = 1
+	// AmfMessage may be addressed to every gNB
+	Capability_CAP_BROADCAST Capability = 2
+	// DRAIN, UNDRAIN and DRAIN_COMPLETE are understood
+	Capability_CAP_DRAIN Capability = 4
+	// LOAD_REPORT is understood
+	Capability_CAP_LOAD_REPORT Capability = 8
+	// the handshake carries every gNB, otherwise sctplb announces each gNB
+	// in an INIT_MSG of its own
+	Capability_CAP_BATCHING Capability = 16
+)
+
+// Enum value maps for Capability.
+var (
+	Capability_name = map[int32]string{
+		0:  "CAP_NONE",
+		1:  "CAP_STREAM_ID",
+		2:  "CAP_BROADCAST",
+		4:  "CAP_DRAIN",
+		8:  "CAP_LOAD_REPORT",
+		16: "CAP_BATCHING",
+	}
+	Capability_value = map[string]int32{
+		"CAP_NONE":        0,
+		"CAP_STREAM_ID":   1,
+		"CAP_BROADCAST":   2,
+		"CAP_DRAIN":       4,
+		"CAP_LOAD_REPORT": 8,
+		"CAP_BATCHING":    16,
+	}
+)
+
+func (x Capability) Enum() *Capability {
+	p := new(Capability)
+	*p = x
+	return p
+}
+
+func (x Capability) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Capability) Descriptor() protoreflect.EnumDescriptor {
+	return file_client_proto_enumTypes[1].Descriptor()
+}
+
+func (Capability) Type() protoreflect.EnumType {
+	return &file_client_proto_enumTypes[1]
+}
+
+func (x Capability) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Capability.Descriptor instead.
+func (Capability) EnumDescriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{1}
+}
+
+// how an AmfMessage is addressed: UNICAST goes to the gNB of GnbId or
+// GnbIpAddr, BROADCAST to every gNB matching the PLMN and TAI filters
+type AddressMode int32
+
+const (
+	AddressMode_UNICAST   AddressMode = 0
+	AddressMode_BROADCAST AddressMode = 1
+)
+
+// Enum value maps for AddressMode.
+var (
+	AddressMode_name = map[int32]string{
+		0: "UNICAST",
+		1: "BROADCAST",
+	}
+	AddressMode_value = map[string]int32{
+		"UNICAST":   0,
+		"BROADCAST": 1,
+	}
+)
+
+func (x AddressMode) Enum() *AddressMode {
+	p := new(AddressMode)
+	*p = x
+	return p
+}
+
+func (x AddressMode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AddressMode) Descriptor() protoreflect.EnumDescriptor {
+	return file_client_proto_enumTypes[2].Descriptor()
+}
+
+func (AddressMode) Type() protoreflect.EnumType {
+	return &file_client_proto_enumTypes[2]
+}
+
+func (x AddressMode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AddressMode.Descriptor instead.
+func (AddressMode) EnumDescriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{2}
+}
+
+type Plmn struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Mcc string `protobuf:"bytes,1,opt,name=Mcc,proto3" json:"Mcc,omitempty"`
+	Mnc string `protobuf:"bytes,2,opt,name=Mnc,proto3" json:"Mnc,omitempty"`
+}
+
+func (x *Plmn) Reset() {
+	*x = Plmn{}
+	mi := &file_client_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Plmn) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Plmn) ProtoMessage() {}
+
+func (x *Plmn) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Plmn.ProtoReflect.Descriptor instead.
+func (*Plmn) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Plmn) GetMcc() string {
+	if x != nil {
+		return x.Mcc
+	}
+	return ""
+}
+
+func (x *Plmn) GetMnc() string {
+	if x != nil {
+		return x.Mnc
+	}
+	return ""
+}
+
+// Tac is the hex encoded tracking area code, e.g. "000001"
+type Tai struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Plmn *Plmn  `protobuf:"bytes,1,opt,name=Plmn,proto3" json:"Plmn,omitempty"`
+	Tac  string `protobuf:"bytes,2,opt,name=Tac,proto3" json:"Tac,omitempty"`
+}
+
+func (x *Tai) Reset() {
+	*x = Tai{}
+	mi := &file_client_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Tai) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Tai) ProtoMessage() {}
+
+func (x *Tai) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Tai.ProtoReflect.Descriptor instead.
+func (*Tai) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Tai) GetPlmn() *Plmn {
+	if x != nil {
+		return x.Plmn
+	}
+	return nil
+}
+
+func (x *Tai) GetTac() string {
+	if x != nil {
+		return x.Tac
+	}
+	return ""
+}
+
+// a gNB known to sctplb when the backend connects
+type GnbInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	GnbId         string   `protobuf:"bytes,1,opt,name=GnbId,proto3" json:"GnbId,omitempty"`
+	Addresses     []string `protobuf:"bytes,2,rep,name=Addresses,proto3" json:"Addresses,omitempty"`
+	SupportedTais []*Tai   `protobuf:"bytes,3,rep,name=SupportedTais,proto3" json:"SupportedTais,omitempty"`
+}
+
+func (x *GnbInfo) Reset() {
+	*x = GnbInfo{}
+	mi := &file_client_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GnbInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GnbInfo) ProtoMessage() {}
+
+func (x *GnbInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GnbInfo.ProtoReflect.Descriptor instead.
+func (*GnbInfo) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GnbInfo) GetGnbId() string {
+	if x != nil {
+		return x.GnbId
+	}
+	return ""
+}
+
+func (x *GnbInfo) GetAddresses() []string {
+	if x != nil {
+		return x.Addresses
+	}
+	return nil
+}
+
+func (x *GnbInfo) GetSupportedTais() []*Tai {
+	if x != nil {
+		return x.SupportedTais
+	}
+	return nil
+}
+
+// first message sctplb sends on a new stream, in an INIT_MSG. The backend
+// answers with an INIT_MSG carrying its own version and capabilities, only
+// the capabilities both sides support are used. An answer without handshake
+// is from a backend of version 0 without any capability.
+type Handshake struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Version      uint32     `protobuf:"varint,1,opt,name=Version,proto3" json:"Version,omitempty"`
+	SctplbId     string     `protobuf:"bytes,2,opt,name=SctplbId,proto3" json:"SctplbId,omitempty"`
+	Capabilities uint64     `protobuf:"varint,3,opt,name=Capabilities,proto3" json:"Capabilities,omitempty"`
+	Gnbs         []*GnbInfo `protobuf:"bytes,4,rep,name=Gnbs,proto3" json:"Gnbs,omitempty"`
+}
+
+func (x *Handshake) Reset() {
+	*x = Handshake{}
+	mi := &file_client_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Handshake) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Handshake) ProtoMessage() {}
+
+func (x *Handshake) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Handshake.ProtoReflect.Descriptor instead.
+func (*Handshake) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Handshake) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Handshake) GetSctplbId() string {
+	if x != nil {
+		return x.SctplbId
+	}
+	return ""
+}
+
+func (x *Handshake) GetCapabilities() uint64 {
+	if x != nil {
+		return x.Capabilities
+	}
+	return 0
+}
+
+func (x *Handshake) GetGnbs() []*GnbInfo {
+	if x != nil {
+		return x.Gnbs
+	}
+	return nil
+}
+
+// LoadReport is the load of an AMF. RelativeCapacity is the capacity of the
+// AMF relative to the others (0..255) as in the NGAP Relative AMF Capacity,
+// Load is its current load in percent of that capacity.
+type LoadReport struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	RelativeCapacity uint32 `protobuf:"varint,1,opt,name=RelativeCapacity,proto3" json:"RelativeCapacity,omitempty"`
+	Load             uint32 `protobuf:"varint,2,opt,name=Load,proto3" json:"Load,omitempty"`
+}
+
+func (x *LoadReport) Reset() {
+	*x = LoadReport{}
+	mi := &file_client_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoadReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoadReport) ProtoMessage() {}
+
+func (x *LoadReport) ProtoReflect() protoreflect.Message {
+	mi := &file_client_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoadReport.ProtoReflect.Descriptor instead.
+func (*LoadReport) Descriptor() ([]byte, []int) {
+	return file_client_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *LoadReport) GetRelativeCapacity() uint32 {
+	if x != nil {
+		return x.RelativeCapacity
+	}
+	return 0
+}
+
+func (x *LoadReport) GetLoad() uint32 {
+	if x != nil {
+		return x.Load
+	}
+	return 0
+}
+
 type SctplbMessage struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -97,11 +525,17 @@ type SctplbMessage struct {
 	VerboseMsg string  `protobuf:"bytes,4,opt,name=VerboseMsg,proto3" json:"VerboseMsg,omitempty"`
 	Msg        []byte  `protobuf:"bytes,5,opt,name=Msg,proto3" json:"Msg,omitempty"`
 	GnbId      string  `protobuf:"bytes,6,opt,name=GnbId,proto3" json:"GnbId,omitempty"`
+	// SCTP stream, payload protocol identifier and unordered flag the
+	// message was received with from the gNB
+	StreamId  uint32     `protobuf:"varint,7,opt,name=StreamId,proto3" json:"StreamId,omitempty"`
+	Ppid      uint32     `protobuf:"varint,8,opt,name=Ppid,proto3" json:"Ppid,omitempty"`
+	Unordered bool       `protobuf:"varint,9,opt,name=Unordered,proto3" json:"Unordered,omitempty"`
+	Handshake *Handshake `protobuf:"bytes,10,opt,name=Handshake,proto3" json:"Handshake,omitempty"`
 }
 
 func (x *SctplbMessage) Reset() {
 	*x = SctplbMessage{}
-	mi := &file_client_proto_msgTypes[0]
+	mi := &file_client_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -113,7 +547,7 @@ func (x *SctplbMessage) String() string {
 func (*SctplbMessage) ProtoMessage() {}
 
 func (x *SctplbMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[0]
+	mi := &file_client_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -126,7 +560,7 @@ func (x *SctplbMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SctplbMessage.ProtoReflect.Descriptor instead.
 func (*SctplbMessage) Descriptor() ([]byte, []int) {
-	return file_client_proto_rawDescGZIP(), []int{0}
+	return file_client_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *SctplbMessage) GetSctplbId() string {
@@ -171,23 +605,65 @@ func (x *SctplbMessage) GetGnbId() string {
 	return ""
 }
 
+func (x *SctplbMessage) GetStreamId() uint32 {
+	if x != nil {
+		return x.StreamId
+	}
+	return 0
+}
+
+func (x *SctplbMessage) GetPpid() uint32 {
+	if x != nil {
+		return x.Ppid
+	}
+	return 0
+}
+
+func (x *SctplbMessage) GetUnordered() bool {
+	if x != nil {
+		return x.Unordered
+	}
+	return false
+}
+
+func (x *SctplbMessage) GetHandshake() *Handshake {
+	if x != nil {
+		return x.Handshake
+	}
+	return nil
+}
+
 type AmfMessage struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	AmfId      string  `protobuf:"bytes,1,opt,name=AmfId,proto3" json:"AmfId,omitempty"`
-	RedirectId string  `protobuf:"bytes,2,opt,name=RedirectId,proto3" json:"RedirectId,omitempty"`
-	Msgtype    MsgType `protobuf:"varint,3,opt,name=Msgtype,proto3,enum=sdcoreAmfServer.MsgType" json:"Msgtype,omitempty"`
-	GnbIpAddr  string  `protobuf:"bytes,4,opt,name=GnbIpAddr,proto3" json:"GnbIpAddr,omitempty"`
-	GnbId      string  `protobuf:"bytes,5,opt,name=GnbId,proto3" json:"GnbId,omitempty"`
-	VerboseMsg string  `protobuf:"bytes,6,opt,name=VerboseMsg,proto3" json:"VerboseMsg,omitempty"`
-	Msg        []byte  `protobuf:"bytes,7,opt,name=Msg,proto3" json:"Msg,omitempty"`
+	AmfId      string      `protobuf:"bytes,1,opt,name=AmfId,proto3" json:"AmfId,omitempty"`
+	RedirectId string      `protobuf:"bytes,2,opt,name=RedirectId,proto3" json:"RedirectId,omitempty"`
+	Msgtype    MsgType     `protobuf:"varint,3,opt,name=Msgtype,proto3,enum=sdcoreAmfServer.MsgType" json:"Msgtype,omitempty"`
+	GnbIpAddr  string      `protobuf:"bytes,4,opt,name=GnbIpAddr,proto3" json:"GnbIpAddr,omitempty"`
+	GnbId      string      `protobuf:"bytes,5,opt,name=GnbId,proto3" json:"GnbId,omitempty"`
+	VerboseMsg string      `protobuf:"bytes,6,opt,name=VerboseMsg,proto3" json:"VerboseMsg,omitempty"`
+	Msg        []byte      `protobuf:"bytes,7,opt,name=Msg,proto3" json:"Msg,omitempty"`
+	Addressing AddressMode `protobuf:"varint,8,opt,name=Addressing,proto3,enum=sdcoreAmfServer.AddressMode" json:"Addressing,omitempty"`
+	// a broadcast only reaches the gNBs that support one of the PLMNs
+	// and one of the TAIs, an empty filter matches every gNB
+	PlmnFilter []*Plmn `protobuf:"bytes,9,rep,name=PlmnFilter,proto3" json:"PlmnFilter,omitempty"`
+	TaiFilter  []*Tai  `protobuf:"bytes,10,rep,name=TaiFilter,proto3" json:"TaiFilter,omitempty"`
+	// SCTP stream, payload protocol identifier and unordered flag the
+	// message is sent to the gNB with. Without a stream sctplb uses stream 0
+	// for non-UE signalling and spreads UE-associated messages over the
+	// other streams by UE, without a PPID it uses the NGAP one.
+	StreamId   *uint32     `protobuf:"varint,11,opt,name=StreamId,proto3,oneof" json:"StreamId,omitempty"`
+	Ppid       uint32      `protobuf:"varint,12,opt,name=Ppid,proto3" json:"Ppid,omitempty"`
+	Unordered  bool        `protobuf:"varint,13,opt,name=Unordered,proto3" json:"Unordered,omitempty"`
+	Handshake  *Handshake  `protobuf:"bytes,14,opt,name=Handshake,proto3" json:"Handshake,omitempty"`
+	LoadReport *LoadReport `protobuf:"bytes,15,opt,name=LoadReport,proto3" json:"LoadReport,omitempty"`
 }
 
 func (x *AmfMessage) Reset() {
 	*x = AmfMessage{}
-	mi := &file_client_proto_msgTypes[1]
+	mi := &file_client_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -199,7 +675,7 @@ func (x *AmfMessage) String() string {
 func (*AmfMessage) ProtoMessage() {}
 
 func (x *AmfMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_client_proto_msgTypes[1]
+	mi := &file_client_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -212,7 +688,7 @@ func (x *AmfMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AmfMessage.ProtoReflect.Descriptor instead.
 func (*AmfMessage) Descriptor() ([]byte, []int) {
-	return file_client_proto_rawDescGZIP(), []int{1}
+	return file_client_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *AmfMessage) GetAmfId() string {
@@ -264,53 +740,184 @@ func (x *AmfMessage) GetMsg() []byte {
 	return nil
 }
 
+func (x *AmfMessage) GetAddressing() AddressMode {
+	if x != nil {
+		return x.Addressing
+	}
+	return AddressMode_UNICAST
+}
+
+func (x *AmfMessage) GetPlmnFilter() []*Plmn {
+	if x != nil {
+		return x.PlmnFilter
+	}
+	return nil
+}
+
+func (x *AmfMessage) GetTaiFilter() []*Tai {
+	if x != nil {
+		return x.TaiFilter
+	}
+	return nil
+}
+
+func (x *AmfMessage) GetStreamId() uint32 {
+	if x != nil && x.StreamId != nil {
+		return *x.StreamId
+	}
+	return 0
+}
+
+func (x *AmfMessage) GetPpid() uint32 {
+	if x != nil {
+		return x.Ppid
+	}
+	return 0
+}
+
+func (x *AmfMessage) GetUnordered() bool {
+	if x != nil {
+		return x.Unordered
+	}
+	return false
+}
+
+func (x *AmfMessage) GetHandshake() *Handshake {
+	if x != nil {
+		return x.Handshake
+	}
+	return nil
+}
+
+func (x *AmfMessage) GetLoadReport() *LoadReport {
+	if x != nil {
+		return x.LoadReport
+	}
+	return nil
+}
+
 var File_client_proto protoreflect.FileDescriptor
 
 var file_client_proto_rawDesc = []byte{
 	0x0a, 0x0c, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0f,
 	0x73, 0x64, 0x63, 0x6f, 0x72, 0x65, 0x41, 0x6d, 0x66, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x22,
-	0xc5, 0x01, 0x0a, 0x0d, 0x53, 0x63, 0x74, 0x70, 0x6c, 0x62, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
-	0x65, 0x12, 0x1a, 0x0a, 0x08, 0x53, 0x63, 0x74, 0x70, 0x6c, 0x62, 0x49, 0x64, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x08, 0x53, 0x63, 0x74, 0x70, 0x6c, 0x62, 0x49, 0x64, 0x12, 0x32, 0x0a,
-	0x07, 0x4d, 0x73, 0x67, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18,
-	0x2e, 0x73, 0x64, 0x63, 0x6f, 0x72, 0x65, 0x41, 0x6d, 0x66, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72,
-	0x2e, 0x6d, 0x73, 0x67, 0x54, 0x79, 0x70, 0x65, 0x52, 0x07, 0x4d, 0x73, 0x67, 0x74, 0x79, 0x70,
-	0x65, 0x12, 0x1c, 0x0a, 0x09, 0x47, 0x6e, 0x62, 0x49, 0x70, 0x41, 0x64, 0x64, 0x72, 0x18, 0x03,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x47, 0x6e, 0x62, 0x49, 0x70, 0x41, 0x64, 0x64, 0x72, 0x12,
-	0x1e, 0x0a, 0x0a, 0x56, 0x65, 0x72, 0x62, 0x6f, 0x73, 0x65, 0x4d, 0x73, 0x67, 0x18, 0x04, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x0a, 0x56, 0x65, 0x72, 0x62, 0x6f, 0x73, 0x65, 0x4d, 0x73, 0x67, 0x12,
-	0x10, 0x0a, 0x03, 0x4d, 0x73, 0x67, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x4d, 0x73,
-	0x67, 0x12, 0x14, 0x0a, 0x05, 0x47, 0x6e, 0x62, 0x49, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x05, 0x47, 0x6e, 0x62, 0x49, 0x64, 0x22, 0xdc, 0x01, 0x0a, 0x0a, 0x41, 0x6d, 0x66, 0x4d,
-	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x41, 0x6d, 0x66, 0x49, 0x64, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x41, 0x6d, 0x66, 0x49, 0x64, 0x12, 0x1e, 0x0a, 0x0a,
-	0x52, 0x65, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x0a, 0x52, 0x65, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x49, 0x64, 0x12, 0x32, 0x0a, 0x07,
-	0x4d, 0x73, 0x67, 0x74, 0x79, 0x70, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e,
+	0x2a, 0x0a, 0x04, 0x50, 0x6c, 0x6d, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x4d, 0x63, 0x63, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4d, 0x63, 0x63, 0x12, 0x10, 0x0a, 0x03, 0x4d, 0x6e, 0x63,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4d, 0x6e, 0x63, 0x22, 0x42, 0x0a, 0x03, 0x54,
+	0x61, 0x69, 0x12, 0x29, 0x0a, 0x04, 0x50, 0x6c, 0x6d, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x15, 0x2e, 0x73, 0x64, 0x63, 0x6f, 0x72, 0x65, 0x41, 0x6d, 0x66, 0x53, 0x65, 0x72, 0x76,
+	0x65, 0x72, 0x2e, 0x50, 0x6c, 0x6d, 0x6e, 0x52, 0x04, 0x50, 0x6c, 0x6d, 0x6e, 0x12, 0x10, 0x0a,
+	0x03, 0x54, 0x61, 0x63, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x54, 0x61, 0x63, 0x22,
+	0x79, 0x0a, 0x07, 0x47, 0x6e, 0x62, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x14, 0x0a, 0x05, 0x47, 0x6e,
+	0x62, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x47, 0x6e, 0x62, 0x49, 0x64,
+	0x12, 0x1c, 0x0a, 0x09, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x18, 0x02, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x09, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x12, 0x3a,
+	0x0a, 0x0d, 0x53, 0x75, 0x70, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x64, 0x54, 0x61, 0x69, 0x73, 0x18,
+	0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x73, 0x64, 0x63, 0x6f, 0x72, 0x65, 0x41, 0x6d,
+	0x66, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x54, 0x61, 0x69, 0x52, 0x0d, 0x53, 0x75, 0x70,
+	0x70, 0x6f, 0x72, 0x74, 0x65, 0x64, 0x54, 0x61, 0x69, 0x73, 0x22, 0x93, 0x01, 0x0a, 0x09, 0x48,
+	0x61, 0x6e, 0x64, 0x73, 0x68, 0x61, 0x6b, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x56, 0x65, 0x72, 0x73,
+	0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x56, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x53, 0x63, 0x74, 0x70, 0x6c, 0x62, 0x49, 0x64, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x53, 0x63, 0x74, 0x70, 0x6c, 0x62, 0x49, 0x64, 0x12, 0x22,
+	0x0a, 0x0c, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x0c, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69,
+	0x65, 0x73, 0x12, 0x2c, 0x0a, 0x04, 0x47, 0x6e, 0x62, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x18, 0x2e, 0x73, 0x64, 0x63, 0x6f, 0x72, 0x65, 0x41, 0x6d, 0x66, 0x53, 0x65, 0x72, 0x76,
+	0x65, 0x72, 0x2e, 0x47, 0x6e, 0x62, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x04, 0x47, 0x6e, 0x62, 0x73,
+	0x22, 0x4c, 0x0a, 0x0a, 0x4c, 0x6f, 0x61, 0x64, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x2a,
+	0x0a, 0x10, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x69, 0x76, 0x65, 0x43, 0x61, 0x70, 0x61, 0x63, 0x69,
+	0x74, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x10, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x69,
+	0x76, 0x65, 0x43, 0x61, 0x70, 0x61, 0x63, 0x69, 0x74, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x4c, 0x6f,
+	0x61, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x4c, 0x6f, 0x61, 0x64, 0x22, 0xcd,
+	0x02, 0x0a, 0x0d, 0x53, 0x63, 0x74, 0x70, 0x6c, 0x62, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x12, 0x1a, 0x0a, 0x08, 0x53, 0x63, 0x74, 0x70, 0x6c, 0x62, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x53, 0x63, 0x74, 0x70, 0x6c, 0x62, 0x49, 0x64, 0x12, 0x32, 0x0a, 0x07,
+	0x4d, 0x73, 0x67, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e,
 	0x73, 0x64, 0x63, 0x6f, 0x72, 0x65, 0x41, 0x6d, 0x66, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e,
 	0x6d, 0x73, 0x67, 0x54, 0x79, 0x70, 0x65, 0x52, 0x07, 0x4d, 0x73, 0x67, 0x74, 0x79, 0x70, 0x65,
-	0x12, 0x1c, 0x0a, 0x09, 0x47, 0x6e, 0x62, 0x49, 0x70, 0x41, 0x64, 0x64, 0x72, 0x18, 0x04, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x09, 0x47, 0x6e, 0x62, 0x49, 0x70, 0x41, 0x64, 0x64, 0x72, 0x12, 0x14,
-	0x0a, 0x05, 0x47, 0x6e, 0x62, 0x49, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x47,
-	0x6e, 0x62, 0x49, 0x64, 0x12, 0x1e, 0x0a, 0x0a, 0x56, 0x65, 0x72, 0x62, 0x6f, 0x73, 0x65, 0x4d,
-	0x73, 0x67, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x56, 0x65, 0x72, 0x62, 0x6f, 0x73,
-	0x65, 0x4d, 0x73, 0x67, 0x12, 0x10, 0x0a, 0x03, 0x4d, 0x73, 0x67, 0x18, 0x07, 0x20, 0x01, 0x28,
-	0x0c, 0x52, 0x03, 0x4d, 0x73, 0x67, 0x2a, 0x6c, 0x0a, 0x07, 0x6d, 0x73, 0x67, 0x54, 0x79, 0x70,
-	0x65, 0x12, 0x0b, 0x0a, 0x07, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x0c,
-	0x0a, 0x08, 0x49, 0x4e, 0x49, 0x54, 0x5f, 0x4d, 0x53, 0x47, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07,
-	0x47, 0x4e, 0x42, 0x5f, 0x4d, 0x53, 0x47, 0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07, 0x41, 0x4d, 0x46,
-	0x5f, 0x4d, 0x53, 0x47, 0x10, 0x03, 0x12, 0x10, 0x0a, 0x0c, 0x52, 0x45, 0x44, 0x49, 0x52, 0x45,
-	0x43, 0x54, 0x5f, 0x4d, 0x53, 0x47, 0x10, 0x04, 0x12, 0x0c, 0x0a, 0x08, 0x47, 0x4e, 0x42, 0x5f,
-	0x44, 0x49, 0x53, 0x43, 0x10, 0x05, 0x12, 0x0c, 0x0a, 0x08, 0x47, 0x4e, 0x42, 0x5f, 0x43, 0x4f,
-	0x4e, 0x4e, 0x10, 0x06, 0x32, 0x61, 0x0a, 0x0b, 0x4e, 0x67, 0x61, 0x70, 0x53, 0x65, 0x72, 0x76,
-	0x69, 0x63, 0x65, 0x12, 0x52, 0x0a, 0x0d, 0x48, 0x61, 0x6e, 0x64, 0x6c, 0x65, 0x4d, 0x65, 0x73,
-	0x73, 0x61, 0x67, 0x65, 0x12, 0x1e, 0x2e, 0x73, 0x64, 0x63, 0x6f, 0x72, 0x65, 0x41, 0x6d, 0x66,
-	0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x53, 0x63, 0x74, 0x70, 0x6c, 0x62, 0x4d, 0x65, 0x73,
-	0x73, 0x61, 0x67, 0x65, 0x1a, 0x1b, 0x2e, 0x73, 0x64, 0x63, 0x6f, 0x72, 0x65, 0x41, 0x6d, 0x66,
-	0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x41, 0x6d, 0x66, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67,
-	0x65, 0x22, 0x00, 0x28, 0x01, 0x30, 0x01, 0x42, 0x13, 0x5a, 0x11, 0x2e, 0x2f, 0x73, 0x64, 0x63,
-	0x6f, 0x72, 0x65, 0x41, 0x6d, 0x66, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x62, 0x06, 0x70, 0x72,
-	0x6f, 0x74, 0x6f, 0x33,
+	0x12, 0x1c, 0x0a, 0x09, 0x47, 0x6e, 0x62, 0x49, 0x70, 0x41, 0x64, 0x64, 0x72, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x09, 0x47, 0x6e, 0x62, 0x49, 0x70, 0x41, 0x64, 0x64, 0x72, 0x12, 0x1e,
+	0x0a, 0x0a, 0x56, 0x65, 0x72, 0x62, 0x6f, 0x73, 0x65, 0x4d, 0x73, 0x67, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0a, 0x56, 0x65, 0x72, 0x62, 0x6f, 0x73, 0x65, 0x4d, 0x73, 0x67, 0x12, 0x10,
+	0x0a, 0x03, 0x4d, 0x73, 0x67, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x4d, 0x73, 0x67,
+	0x12, 0x14, 0x0a, 0x05, 0x47, 0x6e, 0x62, 0x49, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x47, 0x6e, 0x62, 0x49, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x49, 0x64, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x08, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x50, 0x70, 0x69, 0x64, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x04, 0x50, 0x70, 0x69, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x55, 0x6e, 0x6f, 0x72, 0x64, 0x65,
+	0x72, 0x65, 0x64, 0x18, 0x09, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x55, 0x6e, 0x6f, 0x72, 0x64,
+	0x65, 0x72, 0x65, 0x64, 0x12, 0x38, 0x0a, 0x09, 0x48, 0x61, 0x6e, 0x64, 0x73, 0x68, 0x61, 0x6b,
+	0x65, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x73, 0x64, 0x63, 0x6f, 0x72, 0x65,
+	0x41, 0x6d, 0x66, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x48, 0x61, 0x6e, 0x64, 0x73, 0x68,
+	0x61, 0x6b, 0x65, 0x52, 0x09, 0x48, 0x61, 0x6e, 0x64, 0x73, 0x68, 0x61, 0x6b, 0x65, 0x22, 0xdc,
+	0x04, 0x0a, 0x0a, 0x41, 0x6d, 0x66, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x14, 0x0a,
+	0x05, 0x41, 0x6d, 0x66, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x41, 0x6d,
+	0x66, 0x49, 0x64, 0x12, 0x1e, 0x0a, 0x0a, 0x52, 0x65, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x49,
+	0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x52, 0x65, 0x64, 0x69, 0x72, 0x65, 0x63,
+	0x74, 0x49, 0x64, 0x12, 0x32, 0x0a, 0x07, 0x4d, 0x73, 0x67, 0x74, 0x79, 0x70, 0x65, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e, 0x73, 0x64, 0x63, 0x6f, 0x72, 0x65, 0x41, 0x6d, 0x66,
+	0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x6d, 0x73, 0x67, 0x54, 0x79, 0x70, 0x65, 0x52, 0x07,
+	0x4d, 0x73, 0x67, 0x74, 0x79, 0x70, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x47, 0x6e, 0x62, 0x49, 0x70,
+	0x41, 0x64, 0x64, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x47, 0x6e, 0x62, 0x49,
+	0x70, 0x41, 0x64, 0x64, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x47, 0x6e, 0x62, 0x49, 0x64, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x47, 0x6e, 0x62, 0x49, 0x64, 0x12, 0x1e, 0x0a, 0x0a, 0x56,
+	0x65, 0x72, 0x62, 0x6f, 0x73, 0x65, 0x4d, 0x73, 0x67, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0a, 0x56, 0x65, 0x72, 0x62, 0x6f, 0x73, 0x65, 0x4d, 0x73, 0x67, 0x12, 0x10, 0x0a, 0x03, 0x4d,
+	0x73, 0x67, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x4d, 0x73, 0x67, 0x12, 0x3c, 0x0a,
+	0x0a, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6e, 0x67, 0x18, 0x08, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x1c, 0x2e, 0x73, 0x64, 0x63, 0x6f, 0x72, 0x65, 0x41, 0x6d, 0x66, 0x53, 0x65, 0x72,
+	0x76, 0x65, 0x72, 0x2e, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x4d, 0x6f, 0x64, 0x65, 0x52,
+	0x0a, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6e, 0x67, 0x12, 0x35, 0x0a, 0x0a, 0x50,
+	0x6c, 0x6d, 0x6e, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x18, 0x09, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x15, 0x2e, 0x73, 0x64, 0x63, 0x6f, 0x72, 0x65, 0x41, 0x6d, 0x66, 0x53, 0x65, 0x72, 0x76, 0x65,
+	0x72, 0x2e, 0x50, 0x6c, 0x6d, 0x6e, 0x52, 0x0a, 0x50, 0x6c, 0x6d, 0x6e, 0x46, 0x69, 0x6c, 0x74,
+	0x65, 0x72, 0x12, 0x32, 0x0a, 0x09, 0x54, 0x61, 0x69, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x18,
+	0x0a, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x73, 0x64, 0x63, 0x6f, 0x72, 0x65, 0x41, 0x6d,
+	0x66, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x54, 0x61, 0x69, 0x52, 0x09, 0x54, 0x61, 0x69,
+	0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x12, 0x1f, 0x0a, 0x08, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x49, 0x64, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x00, 0x52, 0x08, 0x53, 0x74, 0x72, 0x65,
+	0x61, 0x6d, 0x49, 0x64, 0x88, 0x01, 0x01, 0x12, 0x12, 0x0a, 0x04, 0x50, 0x70, 0x69, 0x64, 0x18,
+	0x0c, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x50, 0x70, 0x69, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x55,
+	0x6e, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x65, 0x64, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09,
+	0x55, 0x6e, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x65, 0x64, 0x12, 0x38, 0x0a, 0x09, 0x48, 0x61, 0x6e,
+	0x64, 0x73, 0x68, 0x61, 0x6b, 0x65, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x73,
+	0x64, 0x63, 0x6f, 0x72, 0x65, 0x41, 0x6d, 0x66, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x48,
+	0x61, 0x6e, 0x64, 0x73, 0x68, 0x61, 0x6b, 0x65, 0x52, 0x09, 0x48, 0x61, 0x6e, 0x64, 0x73, 0x68,
+	0x61, 0x6b, 0x65, 0x12, 0x3b, 0x0a, 0x0a, 0x4c, 0x6f, 0x61, 0x64, 0x52, 0x65, 0x70, 0x6f, 0x72,
+	0x74, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x73, 0x64, 0x63, 0x6f, 0x72, 0x65,
+	0x41, 0x6d, 0x66, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x4c, 0x6f, 0x61, 0x64, 0x52, 0x65,
+	0x70, 0x6f, 0x72, 0x74, 0x52, 0x0a, 0x4c, 0x6f, 0x61, 0x64, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74,
+	0x42, 0x0b, 0x0a, 0x09, 0x5f, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x64, 0x2a, 0xa9, 0x01,
+	0x0a, 0x07, 0x6d, 0x73, 0x67, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0b, 0x0a, 0x07, 0x55, 0x4e, 0x4b,
+	0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x49, 0x4e, 0x49, 0x54, 0x5f, 0x4d,
+	0x53, 0x47, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x47, 0x4e, 0x42, 0x5f, 0x4d, 0x53, 0x47, 0x10,
+	0x02, 0x12, 0x0b, 0x0a, 0x07, 0x41, 0x4d, 0x46, 0x5f, 0x4d, 0x53, 0x47, 0x10, 0x03, 0x12, 0x10,
+	0x0a, 0x0c, 0x52, 0x45, 0x44, 0x49, 0x52, 0x45, 0x43, 0x54, 0x5f, 0x4d, 0x53, 0x47, 0x10, 0x04,
+	0x12, 0x0c, 0x0a, 0x08, 0x47, 0x4e, 0x42, 0x5f, 0x44, 0x49, 0x53, 0x43, 0x10, 0x05, 0x12, 0x0c,
+	0x0a, 0x08, 0x47, 0x4e, 0x42, 0x5f, 0x43, 0x4f, 0x4e, 0x4e, 0x10, 0x06, 0x12, 0x09, 0x0a, 0x05,
+	0x44, 0x52, 0x41, 0x49, 0x4e, 0x10, 0x07, 0x12, 0x0b, 0x0a, 0x07, 0x55, 0x4e, 0x44, 0x52, 0x41,
+	0x49, 0x4e, 0x10, 0x08, 0x12, 0x12, 0x0a, 0x0e, 0x44, 0x52, 0x41, 0x49, 0x4e, 0x5f, 0x43, 0x4f,
+	0x4d, 0x50, 0x4c, 0x45, 0x54, 0x45, 0x10, 0x09, 0x12, 0x0f, 0x0a, 0x0b, 0x4c, 0x4f, 0x41, 0x44,
+	0x5f, 0x52, 0x45, 0x50, 0x4f, 0x52, 0x54, 0x10, 0x0a, 0x2a, 0x76, 0x0a, 0x0a, 0x63, 0x61, 0x70,
+	0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x12, 0x0c, 0x0a, 0x08, 0x43, 0x41, 0x50, 0x5f, 0x4e,
+	0x4f, 0x4e, 0x45, 0x10, 0x00, 0x12, 0x11, 0x0a, 0x0d, 0x43, 0x41, 0x50, 0x5f, 0x53, 0x54, 0x52,
+	0x45, 0x41, 0x4d, 0x5f, 0x49, 0x44, 0x10, 0x01, 0x12, 0x11, 0x0a, 0x0d, 0x43, 0x41, 0x50, 0x5f,
+	0x42, 0x52, 0x4f, 0x41, 0x44, 0x43, 0x41, 0x53, 0x54, 0x10, 0x02, 0x12, 0x0d, 0x0a, 0x09, 0x43,
+	0x41, 0x50, 0x5f, 0x44, 0x52, 0x41, 0x49, 0x4e, 0x10, 0x04, 0x12, 0x13, 0x0a, 0x0f, 0x43, 0x41,
+	0x50, 0x5f, 0x4c, 0x4f, 0x41, 0x44, 0x5f, 0x52, 0x45, 0x50, 0x4f, 0x52, 0x54, 0x10, 0x08, 0x12,
+	0x10, 0x0a, 0x0c, 0x43, 0x41, 0x50, 0x5f, 0x42, 0x41, 0x54, 0x43, 0x48, 0x49, 0x4e, 0x47, 0x10,
+	0x10, 0x2a, 0x29, 0x0a, 0x0b, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x4d, 0x6f, 0x64, 0x65,
+	0x12, 0x0b, 0x0a, 0x07, 0x55, 0x4e, 0x49, 0x43, 0x41, 0x53, 0x54, 0x10, 0x00, 0x12, 0x0d, 0x0a,
+	0x09, 0x42, 0x52, 0x4f, 0x41, 0x44, 0x43, 0x41, 0x53, 0x54, 0x10, 0x01, 0x32, 0x61, 0x0a, 0x0b,
+	0x4e, 0x67, 0x61, 0x70, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x52, 0x0a, 0x0d, 0x48,
+	0x61, 0x6e, 0x64, 0x6c, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x1e, 0x2e, 0x73,
+	0x64, 0x63, 0x6f, 0x72, 0x65, 0x41, 0x6d, 0x66, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x53,
+	0x63, 0x74, 0x70, 0x6c, 0x62, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x1a, 0x1b, 0x2e, 0x73,
+	0x64, 0x63, 0x6f, 0x72, 0x65, 0x41, 0x6d, 0x66, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x41,
+	0x6d, 0x66, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x00, 0x28, 0x01, 0x30, 0x01, 0x42,
+	0x13, 0x5a, 0x11, 0x2e, 0x2f, 0x73, 0x64, 0x63, 0x6f, 0x72, 0x65, 0x41, 0x6d, 0x66, 0x53, 0x65,
+	0x72, 0x76, 0x65, 0x72, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -325,23 +932,39 @@ func file_client_proto_rawDescGZIP() []byte {
 	return file_client_proto_rawDescData
 }
 
-var file_client_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_client_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_client_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_client_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
 var file_client_proto_goTypes = []any{
 	(MsgType)(0),          // 0: sdcoreAmfServer.msgType
-	(*SctplbMessage)(nil), // 1: sdcoreAmfServer.SctplbMessage
-	(*AmfMessage)(nil),    // 2: sdcoreAmfServer.AmfMessage
+	(Capability)(0),       // 1: sdcoreAmfServer.capability
+	(AddressMode)(0),      // 2: sdcoreAmfServer.addressMode
+	(*Plmn)(nil),          // 3: sdcoreAmfServer.Plmn
+	(*Tai)(nil),           // 4: sdcoreAmfServer.Tai
+	(*GnbInfo)(nil),       // 5: sdcoreAmfServer.GnbInfo
+	(*Handshake)(nil),     // 6: sdcoreAmfServer.Handshake
+	(*LoadReport)(nil),    // 7: sdcoreAmfServer.LoadReport
+	(*SctplbMessage)(nil), // 8: sdcoreAmfServer.SctplbMessage
+	(*AmfMessage)(nil),    // 9: sdcoreAmfServer.AmfMessage
 }
 var file_client_proto_depIdxs = []int32{
-	0, // 0: sdcoreAmfServer.SctplbMessage.Msgtype:type_name -> sdcoreAmfServer.msgType
-	0, // 1: sdcoreAmfServer.AmfMessage.Msgtype:type_name -> sdcoreAmfServer.msgType
-	1, // 2: sdcoreAmfServer.NgapService.HandleMessage:input_type -> sdcoreAmfServer.SctplbMessage
-	2, // 3: sdcoreAmfServer.NgapService.HandleMessage:output_type -> sdcoreAmfServer.AmfMessage
-	3, // [3:4] is the sub-list for method output_type
-	2, // [2:3] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	3,  // 0: sdcoreAmfServer.Tai.Plmn:type_name -> sdcoreAmfServer.Plmn
+	4,  // 1: sdcoreAmfServer.GnbInfo.SupportedTais:type_name -> sdcoreAmfServer.Tai
+	5,  // 2: sdcoreAmfServer.Handshake.Gnbs:type_name -> sdcoreAmfServer.GnbInfo
+	0,  // 3: sdcoreAmfServer.SctplbMessage.Msgtype:type_name -> sdcoreAmfServer.msgType
+	6,  // 4: sdcoreAmfServer.SctplbMessage.Handshake:type_name -> sdcoreAmfServer.Handshake
+	0,  // 5: sdcoreAmfServer.AmfMessage.Msgtype:type_name -> sdcoreAmfServer.msgType
+	2,  // 6: sdcoreAmfServer.AmfMessage.Addressing:type_name -> sdcoreAmfServer.addressMode
+	3,  // 7: sdcoreAmfServer.AmfMessage.PlmnFilter:type_name -> sdcoreAmfServer.Plmn
+	4,  // 8: sdcoreAmfServer.AmfMessage.TaiFilter:type_name -> sdcoreAmfServer.Tai
+	6,  // 9: sdcoreAmfServer.AmfMessage.Handshake:type_name -> sdcoreAmfServer.Handshake
+	7,  // 10: sdcoreAmfServer.AmfMessage.LoadReport:type_name -> sdcoreAmfServer.LoadReport
+	8,  // 11: sdcoreAmfServer.NgapService.HandleMessage:input_type -> sdcoreAmfServer.SctplbMessage
+	9,  // 12: sdcoreAmfServer.NgapService.HandleMessage:output_type -> sdcoreAmfServer.AmfMessage
+	12, // [12:13] is the sub-list for method output_type
+	11, // [11:12] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
 }
 
 func init() { file_client_proto_init() }
@@ -349,13 +972,14 @@ func file_client_proto_init() {
 	if File_client_proto != nil {
 		return
 	}
+	file_client_proto_msgTypes[6].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_client_proto_rawDesc,
-			NumEnums:      1,
-			NumMessages:   2,
+			NumEnums:      3,
+			NumMessages:   7,
 			NumExtensions: 0,
 			NumServices:   1,
 		},